@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+)
+
+// Config holds mocket's runtime options.  Every option can be given as a
+// command-line flag or in a JSON config file; flags win when both are set.
+type Config struct {
+	File             string `json:"-"`
+	Port             string `json:"port"`
+	ScriptDir        string `json:"scriptDir"`
+	ScriptFolders    string `json:"scriptFolders"`
+	Include          string `json:"include"`
+	Exclude          string `json:"exclude"`
+	FallbackUpstream string `json:"fallbackUpstream"`
+	Record           string `json:"record"`
+	RecordStrip      string `json:"recordStrip"`
+	RecordDedupe     bool   `json:"recordDedupe"`
+	Watch            string `json:"watch"`
+	Seed             string `json:"seed"`
+}
+
+func (c *Config) Flags(f *flag.FlagSet) {
+	f.StringVar(&c.File, "c", "", "JSON config file.")
+	f.StringVar(&c.Port, "p", "80", "Port to listen on.")
+	f.StringVar(&c.ScriptDir, "s", "./scripts", "Script directory.")
+	f.StringVar(&c.ScriptFolders, "script-folders", "",
+		"What nested script folders mean: \"prefix\" or \"host\".")
+	f.StringVar(&c.Include, "include", "",
+		"Comma-separated globs of the scripts to load.")
+	f.StringVar(&c.Exclude, "exclude", "",
+		"Comma-separated globs of scripts not to load.")
+	f.StringVar(&c.FallbackUpstream, "fallback-upstream", "",
+		"Upstream to proxy requests that match no script to.")
+	f.StringVar(&c.Record, "record", "",
+		"Upstream to proxy every request to, recording each as a script.")
+	f.StringVar(&c.RecordStrip, "record-strip", "Date,X-Request-Id",
+		"Comma-separated response headers to leave out of recordings.")
+	f.BoolVar(&c.RecordDedupe, "record-dedupe", false,
+		"Record identical requests to a single script.")
+	f.StringVar(&c.Watch, "watch", "",
+		"Interval to poll the script directory for changes at, e.g. 2s.")
+	f.StringVar(&c.Seed, "seed", "",
+		"Seed for the random values in responses, for repeatable runs.")
+}
+
+// Parse reads the command line and, if one was named, the config file.  The
+// command line is parsed a second time after the file is read so that
+// explicit flags override it.
+func (c *Config) Parse(f *flag.FlagSet, args []string) error {
+	if err := f.Parse(args); err != nil {
+		return err
+	} else if c.File == "" {
+		return nil
+	}
+
+	if data, err := os.ReadFile(c.File); err != nil {
+		return err
+	} else if err := json.Unmarshal(data, c); err != nil {
+		return err
+	}
+
+	return f.Parse(args)
+}
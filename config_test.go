@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func parseConfig(t *testing.T, args ...string) *Config {
+	config := new(Config)
+	f := flag.NewFlagSet("mocket", flag.ContinueOnError)
+	config.Flags(f)
+	if err := config.Parse(f, args); err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+	return config
+}
+
+func writeConfig(t *testing.T, content string) string {
+	file := filepath.Join(t.TempDir(), "mocket.json")
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+	return file
+}
+
+// should keep each option's default when nothing sets it
+func TestConfigDefaults(t *testing.T) {
+	config := parseConfig(t)
+	if config.Port != "80" || config.ScriptDir != "./scripts" ||
+		config.RecordStrip != "Date,X-Request-Id" || config.RecordDedupe {
+		t.Errorf("unexpected defaults %+v", config)
+	}
+}
+
+// should read options from a config file, over the defaults
+func TestConfigFile(t *testing.T) {
+	file := writeConfig(t, `{
+		"port": "8080",
+		"scriptDir": "mocks",
+		"fallbackUpstream": "http://api.example.com",
+		"recordDedupe": true
+	}`)
+
+	config := parseConfig(t, "-c", file)
+	if config.Port != "8080" || config.ScriptDir != "mocks" ||
+		config.FallbackUpstream != "http://api.example.com" ||
+		!config.RecordDedupe {
+		t.Errorf("expected the file's options, received %+v", config)
+	}
+	if config.RecordStrip != "Date,X-Request-Id" {
+		t.Errorf("expected the default for an option the file doesn't set, "+
+			"received %q", config.RecordStrip)
+	}
+}
+
+// should let flags override the config file, wherever they're given
+func TestConfigFlagsOverrideFile(t *testing.T) {
+	file := writeConfig(t, `{ "port": "8080", "scriptDir": "mocks" }`)
+
+	for _, args := range [][]string{
+		{"-p", "9090", "-c", file},
+		{"-c", file, "-p", "9090"},
+	} {
+		config := parseConfig(t, args...)
+		if config.Port != "9090" || config.ScriptDir != "mocks" {
+			t.Errorf("%v: expected port 9090 and the file's script dir, "+
+				"received %+v", args, config)
+		}
+	}
+}
+
+// should fail on a config file that's missing or isn't JSON
+func TestConfigFileErrors(t *testing.T) {
+	for _, file := range []string{
+		filepath.Join(t.TempDir(), "missing.json"),
+		writeConfig(t, `port: 8080`),
+	} {
+		config := new(Config)
+		f := flag.NewFlagSet("mocket", flag.ContinueOnError)
+		config.Flags(f)
+		if err := config.Parse(f, []string{"-c", file}); err == nil {
+			t.Errorf("%s: expected an error", file)
+		}
+	}
+}
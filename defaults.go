@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/infinadam/mocket/router"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultsName is the name, less its extension, of the file holding the
+// defaults for every script in its folder and the folders beneath it.
+const DefaultsName = "_defaults"
+
+// defaults is a partial script merged beneath each script it applies to.
+// Its "basePath", if any, is put in front of each script's url or path.
+type defaults map[string]any
+
+func isDefaults(rel string) bool {
+	base := path.Base(rel)
+	return strings.TrimSuffix(base, path.Ext(base)) == DefaultsName
+}
+
+func readDefaults(l *layout, rel string) (defaults, error) {
+	var d defaults
+
+	script, err := os.ReadFile(filepath.Join(l.dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, err
+	} else if script, err = router.ScriptToJSON(rel, script); err != nil {
+		return nil, err
+	}
+
+	return d, json.Unmarshal(script, &d)
+}
+
+// over merges other over d, returning the result without changing either.
+// Objects are merged key by key, and anything else in other replaces what's
+// in d.
+func (d defaults) over(other map[string]any) map[string]any {
+	merged := make(map[string]any)
+	for k, v := range d {
+		merged[k] = v
+	}
+
+	for k, v := range other {
+		inner, isMap := merged[k].(map[string]any)
+		if o, ok := v.(map[string]any); ok && isMap {
+			merged[k] = defaults(inner).over(o)
+		} else {
+			merged[k] = v
+		}
+	}
+
+	return merged
+}
+
+// apply merges a script over the defaults, leaving TCP scripts as they are.
+func (d defaults) apply(script []byte) ([]byte, error) {
+	if len(d) == 0 || isTCP(script) {
+		return script, nil
+	}
+
+	var s map[string]any
+	if err := json.Unmarshal(script, &s); err != nil {
+		return nil, err
+	}
+
+	base, _ := d["basePath"].(string)
+	merged := d.over(s)
+	delete(merged, "basePath")
+
+	if request, ok := merged["request"].(map[string]any); ok && base != "" {
+		request = defaults(request).over(nil)
+		for _, key := range []string{"url", "path"} {
+			if p, ok := request[key].(string); ok {
+				request[key] = strings.TrimSuffix(base, "/") + "/" +
+					strings.TrimPrefix(p, "/")
+			}
+		}
+		merged["request"] = request
+	}
+
+	return json.Marshal(merged)
+}
+
+// splitScripts finds the scripts in a file: one script, a list of them, or
+// an object of "mocks" with the "defaults" they share.  list reports whether
+// the file held a list.
+func splitScripts(file []byte) (d defaults, mocks []json.RawMessage,
+	list bool, err error) {
+	if trimmed := bytes.TrimSpace(file); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &mocks)
+		return nil, mocks, true, err
+	}
+
+	var grouped struct {
+		Defaults defaults          `json:"defaults"`
+		Mocks    []json.RawMessage `json:"mocks"`
+	}
+	if json.Unmarshal(file, &grouped) == nil && grouped.Mocks != nil {
+		return grouped.Defaults, grouped.Mocks, true, nil
+	} else if grouped.Defaults != nil {
+		return nil, nil, false, errors.New("defaults without any mocks")
+	}
+
+	return nil, []json.RawMessage{file}, false, nil
+}
+
+// folderDefaults merges the defaults of each folder from the script
+// directory down to dir, so that nearer folders take precedence.
+func folderDefaults(byFolder map[string]defaults, dir string) defaults {
+	merged := byFolder["."]
+	if dir == "." {
+		return merged
+	}
+
+	folders := strings.Split(dir, "/")
+	for i := range folders {
+		merged = merged.over(byFolder[strings.Join(folders[:i+1], "/")])
+	}
+	return merged
+}
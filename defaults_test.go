@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// should find the scripts in a single script, a list and a grouped file
+func TestSplitScripts(t *testing.T) {
+	tests := []struct {
+		file     string
+		defaults defaults
+		count    int
+		list     bool
+	}{
+		{`{"request": {}}`, nil, 1, false},
+		{` [{"request": {}}, {"request": {}}]`, nil, 2, true},
+		{`{"defaults": {"basePath": "/v1"}, "mocks": [{"request": {}}]}`,
+			defaults{"basePath": "/v1"}, 1, true},
+	}
+
+	for _, test := range tests {
+		d, mocks, list, err := splitScripts([]byte(test.file))
+		if err != nil {
+			t.Fatalf("received error (%v) for %s", err, test.file)
+		}
+		if !reflect.DeepEqual(d, test.defaults) || len(mocks) != test.count ||
+			list != test.list {
+			t.Errorf("unexpected split of %s: %v, %d, %v", test.file, d,
+				len(mocks), list)
+		}
+	}
+
+	if _, _, _, err := splitScripts([]byte(`{"defaults": {}}`)); err == nil {
+		t.Error("expected an error for defaults without mocks")
+	}
+}
+
+// should merge objects key by key, with nearer folders taking precedence
+func TestFolderDefaults(t *testing.T) {
+	byFolder := map[string]defaults{
+		".": {"basePath": "/api", "response": map[string]any{
+			"status": 200.0, "headers": map[string]any{"x-root": "1"}}},
+		"a": {"response": map[string]any{
+			"headers": map[string]any{"x-a": "1"}}},
+		"a/b": {"basePath": "/api/b", "response": map[string]any{
+			"headers": map[string]any{"x-root": "2"}}},
+	}
+
+	expected := defaults{"basePath": "/api/b", "response": map[string]any{
+		"status":  200.0,
+		"headers": map[string]any{"x-root": "2", "x-a": "1"},
+	}}
+	if d := folderDefaults(byFolder, "a/b"); !reflect.DeepEqual(d, expected) {
+		t.Errorf("expected %v, received %v", expected, d)
+	}
+
+	if d := folderDefaults(byFolder, "."); !reflect.DeepEqual(d,
+		byFolder["."]) {
+		t.Errorf("expected the root defaults, received %v", d)
+	}
+}
+
+// should put the base path in front of a script's url, under the script
+func TestApplyDefaults(t *testing.T) {
+	d := defaults{"basePath": "/v1/", "response": map[string]any{
+		"status": 200.0, "headers": map[string]any{"x-a": "1"}}}
+
+	merged, err := d.apply([]byte(`{
+		"request": { "method": "get", "url": "/users" },
+		"response": { "headers": { "x-b": "2" } }
+	}`))
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	var script map[string]any
+	json.Unmarshal(merged, &script)
+	expected := map[string]any{
+		"request": map[string]any{"method": "get", "url": "/v1/users"},
+		"response": map[string]any{"status": 200.0,
+			"headers": map[string]any{"x-a": "1", "x-b": "2"}},
+	}
+	if !reflect.DeepEqual(script, expected) {
+		t.Errorf("expected %v, received %v", expected, script)
+	}
+
+	tcp := []byte(`{"tcp": {}}`)
+	if merged, _ := d.apply(tcp); string(merged) != string(tcp) {
+		t.Errorf("expected a TCP script to be left alone, received %s",
+			merged)
+	}
+}
+
+// should load folder, file and script defaults over one another
+func TestLoadScriptsDefaults(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"_defaults.json": `{
+			"response": { "status": 200, "headers": { "x-root": "1" } }
+		}`,
+		"billing/_defaults.yaml": "basePath: /billing\n" +
+			"response: { headers: { x-folder: '1' } }\n",
+		"billing/invoices.json": `{
+			"defaults": { "response": { "headers": { "x-file": "1" } } },
+			"mocks": [
+				{ "request": { "method": "get", "url": "/invoices" } },
+				{
+					"request": { "method": "post", "url": "/invoices" },
+					"response": { "status": 201, "headers": { "x-root": "2" } }
+				}
+			]
+		}`,
+	})
+
+	server, err := MakeServer(&Config{ScriptDir: dir})
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	if w := serve(server, "GET", "/invoices"); w.Code != 404 {
+		t.Errorf("expected the base path to be required, received %d", w.Code)
+	}
+
+	tests := []struct {
+		method  string
+		status  int
+		headers map[string]string
+	}{
+		{"GET", 200, map[string]string{"x-root": "1", "x-folder": "1",
+			"x-file": "1"}},
+		{"POST", 201, map[string]string{"x-root": "2", "x-folder": "1",
+			"x-file": "1"}},
+	}
+
+	for _, test := range tests {
+		w := serve(server, test.method, "/billing/invoices")
+		if w.Code != test.status {
+			t.Errorf("%s: expected status %d, received %d", test.method,
+				test.status, w.Code)
+		}
+		for k, v := range test.headers {
+			if w.Header().Get(k) != v {
+				t.Errorf("%s: expected %q to be %q, was %q", test.method, k, v,
+					w.Header().Get(k))
+			}
+		}
+	}
+}
+
+// should name a script in a list by its index when it fails to load
+func TestLoadScriptsListError(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"list.json": `[
+			{ "request": { "method": "get", "url": "/a" } },
+			{ "request": { "method": "fetch", "url": "/b" } }
+		]`,
+	})
+
+	l, _ := makeLayout(&Config{ScriptDir: dir})
+	_, err := loadScripts(l)
+	if err == nil || !strings.HasPrefix(err.Error(), "list.json[1]: ") {
+		t.Errorf("expected an error naming list.json[1], received %v", err)
+	}
+
+	dir = writeScripts(t, map[string]string{
+		"_defaults.json": `{}`,
+		"_defaults.yaml": `{}`,
+	})
+	l, _ = makeLayout(&Config{ScriptDir: dir})
+	if _, err := loadScripts(l); err == nil {
+		t.Error("expected an error for a folder with two defaults")
+	}
+}
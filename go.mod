@@ -1,3 +1,8 @@
 module github.com/infinadam/mocket
 
 go 1.21.7
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	gopkg.in/yaml.v3 v3.0.1
+)
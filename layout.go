@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"github.com/infinadam/mocket/router"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// What nested script folders mean.  By default they only organize scripts;
+// FoldersPrefix makes them a prefix of each script's path, and FoldersHost
+// makes the first of them the host each script answers for.
+const (
+	FoldersPrefix = "prefix"
+	FoldersHost   = "host"
+)
+
+// scriptExtensions are the files loaded as scripts; anything else in the
+// script directory, such as the files bodies are streamed from, is left alone.
+var scriptExtensions = map[string]bool{
+	".json": true, ".yaml": true, ".yml": true, ".toml": true,
+}
+
+// layout describes how scripts are found beneath the script directory.
+type layout struct {
+	dir     string
+	folders string
+	include []string
+	exclude []string
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func makeLayout(config *Config) (*layout, error) {
+	l := &layout{
+		dir:     config.ScriptDir,
+		folders: config.ScriptFolders,
+		include: splitList(config.Include),
+		exclude: splitList(config.Exclude),
+	}
+
+	switch l.folders {
+	case "", FoldersPrefix, FoldersHost:
+	default:
+		return nil, fmt.Errorf("unrecognized script folders (%s)", l.folders)
+	}
+
+	for _, pattern := range append(l.include, l.exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad pattern (%s)", pattern)
+		}
+	}
+
+	return l, nil
+}
+
+// matches reports whether a script's path, relative to the script directory,
+// or any folder it's in matches one of patterns.
+func matches(patterns []string, rel string) bool {
+	segments := strings.Split(rel, "/")
+	for i := range segments {
+		prefix := strings.Join(segments[:i+1], "/")
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, prefix); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// files walks the script directory, giving the path of every script that's
+// included and not excluded, and of every defaults file, relative to the
+// directory and with slashes.
+func (l *layout) files() ([]string, error) {
+	var files []string
+
+	err := filepath.WalkDir(l.dir, func(p string, d fs.DirEntry,
+		err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(l.dir, p)
+		if err != nil {
+			return err
+		}
+
+		// defaults apply to whichever scripts are loaded beside them.
+		rel = filepath.ToSlash(rel)
+		if !scriptExtensions[strings.ToLower(path.Ext(rel))] {
+			return nil
+		} else if !isDefaults(rel) && (len(l.include) > 0 &&
+			!matches(l.include, rel) || matches(l.exclude, rel)) {
+			return nil
+		}
+
+		files = append(files, rel)
+		return nil
+	})
+
+	return files, err
+}
+
+// bodyFile resolves the file a script streams its body from against the
+// script's folder, and makes sure it can be read.
+func (l *layout) bodyFile(action *router.HTTPAction, rel string) error {
+	file := &action.Response.File
+	if *file == "" {
+		return nil
+	} else if !filepath.IsAbs(*file) {
+		*file = filepath.Join(l.dir, filepath.FromSlash(path.Dir(rel)),
+			filepath.FromSlash(*file))
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// scope applies the folders a script is in to its route, according to the
+// layout.
+func (l *layout) scope(action *router.HTTPAction, rel string) {
+	dir := path.Dir(rel)
+	if dir == "." {
+		return
+	}
+	folders := strings.Split(dir, "/")
+
+	switch l.folders {
+	case FoldersPrefix:
+		prefix := make([]*regexp.Regexp, len(folders))
+		for i, f := range folders {
+			prefix[i] = regexp.MustCompile("^" + regexp.QuoteMeta(f) + "$")
+		}
+
+		p := action.Request.Path
+		action.Request.Path = append(append(p[:1:1], prefix...), p[1:]...)
+	case FoldersHost:
+		if action.Request.Host == nil {
+			host := strings.ToLower(folders[0])
+			action.Request.Host = regexp.MustCompile(
+				"^" + regexp.QuoteMeta(host) + "$")
+		}
+	}
+}
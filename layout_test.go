@@ -0,0 +1,120 @@
+package main
+
+import (
+	"github.com/infinadam/mocket/router"
+	"reflect"
+	"testing"
+)
+
+// should walk nested folders for scripts, keeping defaults through filters
+func TestLayoutFiles(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"_defaults.json":          `{}`,
+		"users.json":              `{}`,
+		"billing/_defaults.yaml":  `{}`,
+		"billing/invoices.yaml":   `{}`,
+		"billing/legacy/old.toml": ``,
+		"billing/body.png":        ``,
+		"stripe/charges.json":     `{}`,
+	})
+
+	tests := []struct {
+		include, exclude string
+		expected         []string
+	}{
+		{"", "", []string{"_defaults.json", "billing/_defaults.yaml",
+			"billing/invoices.yaml", "billing/legacy/old.toml",
+			"stripe/charges.json", "users.json"}},
+		{"billing", "billing/legacy", []string{"_defaults.json",
+			"billing/_defaults.yaml", "billing/invoices.yaml"}},
+		{"*.json, stripe", "", []string{"_defaults.json",
+			"billing/_defaults.yaml", "stripe/charges.json", "users.json"}},
+		{"", "*/*.yaml,stripe", []string{"_defaults.json",
+			"billing/_defaults.yaml", "billing/legacy/old.toml",
+			"users.json"}},
+	}
+
+	for _, test := range tests {
+		l, err := makeLayout(&Config{ScriptDir: dir, Include: test.include,
+			Exclude: test.exclude})
+		if err != nil {
+			t.Fatalf("received error (%v)", err)
+		}
+
+		if files, err := l.files(); err != nil {
+			t.Errorf("received error (%v)", err)
+		} else if !reflect.DeepEqual(files, test.expected) {
+			t.Errorf("including %q and excluding %q, expected %v, received %v",
+				test.include, test.exclude, test.expected, files)
+		}
+	}
+
+	if _, err := makeLayout(&Config{Include: "[a"}); err == nil {
+		t.Error("expected an error for a bad pattern")
+	}
+	if _, err := makeLayout(&Config{ScriptFolders: "path"}); err == nil {
+		t.Error("expected an error for unrecognized script folders")
+	}
+}
+
+func scopedAction(t *testing.T, folders string, rel string,
+	script string) *router.HTTPAction {
+	action, err := router.HTTPActionFromJSON([]byte(script))
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	l := &layout{folders: folders}
+	l.scope(action, rel)
+	return action
+}
+
+// should put a script's folders in front of its path in prefix mode
+func TestLayoutScopePrefix(t *testing.T) {
+	script := `{ "request": { "method": "get", "url": "/invoices/2024" } }`
+	action := scopedAction(t, FoldersPrefix, "billing/v1.2/get.json", script)
+
+	var path []string
+	for _, re := range action.Request.Path {
+		path = append(path, re.String())
+	}
+	expected := []string{"^get$", "^billing$", `^v1\.2$`, "^invoices$",
+		"^2024$"}
+	if !reflect.DeepEqual(path, expected) {
+		t.Errorf("expected %v, received %v", expected, path)
+	}
+
+	if action := scopedAction(t, "", "billing/get.json", script); len(
+		action.Request.Path) != 3 {
+		t.Errorf("expected folders to be ignored by default, received %v",
+			action.Request.Path)
+	}
+}
+
+// should make a script's first folder its host in host mode, unless it has one
+func TestLayoutScopeHost(t *testing.T) {
+	script := `{ "request": { "method": "get", "url": "/invoices" } }`
+	action := scopedAction(t, FoldersHost, "API.Test/v1/get.json", script)
+	if action.Request.Host == nil ||
+		action.Request.Host.String() != `^api\.test$` {
+		t.Errorf("expected host \"^api\\.test$\", received %v",
+			action.Request.Host)
+	}
+	if len(action.Request.Path) != 2 {
+		t.Errorf("expected the path to be left alone, received %v",
+			action.Request.Path)
+	}
+
+	action = scopedAction(t, FoldersHost, "api.test/get.json", `{
+		"request": { "method": "get", "url": "/invoices", "host": "other" }
+	}`)
+	if action.Request.Host.MatchString("api.test") {
+		t.Error("expected the script's own host to be kept")
+	}
+
+	if action := scopedAction(t, FoldersHost, "get.json",
+		script); action.Request.Host != nil {
+		t.Errorf("expected no host at the top level, received %v",
+			action.Request.Host)
+	}
+}
@@ -2,22 +2,73 @@ package main
 
 import (
 	"flag"
+	"github.com/infinadam/mocket/router"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 )
 
-var port = flag.String("p", "80", "Port to listen on.")
-var scriptDir = flag.String("s", "./scripts", "Script directory.")
-
 func main() {
-	flag.Parse()
+	var config Config
+	config.Flags(flag.CommandLine)
+	if err := config.Parse(flag.CommandLine, os.Args[1:]); err != nil {
+		log.Fatalf("mocket: error reading config (%v)", err)
+	}
 
-	log.Printf("mocket: reading script directory (%s)...\n", *scriptDir)
-	if server, err := MakeServer(*scriptDir); err != nil {
-		log.Fatalf("mocket: error making server (%v)", err)
+	if config.Record != "" {
+		log.Printf("mocket: recording (%s) to (%s)...\n", config.Record,
+			config.ScriptDir)
+		if recorder, err := MakeRecorder(&config); err != nil {
+			log.Fatalf("mocket: error making recorder (%v)", err)
+		} else {
+			http.HandleFunc("/", recorder.HandleRequest)
+		}
 	} else {
-		log.Printf("mocket: starting on (%s)...\n", *port)
-		http.HandleFunc("/", server.HandleRequest)
-		http.ListenAndServe(":"+*port, nil)
+		if config.Seed != "" {
+			seed(&config)
+		}
+
+		log.Printf("mocket: reading script directory (%s)...\n", config.ScriptDir)
+		if server, err := MakeServer(&config); err != nil {
+			log.Fatalf("mocket: error making server (%v)", err)
+		} else if err := server.ServeTCP(); err != nil {
+			log.Fatalf("mocket: error listening for tcp (%v)", err)
+		} else {
+			http.HandleFunc("/", server.HandleRequest)
+			http.HandleFunc(router.ScenariosPath, server.scenarios.HandleAdmin)
+			http.HandleFunc(router.ScenariosPath+"/",
+				server.scenarios.HandleAdmin)
+			if config.Watch != "" {
+				watch(server, &config)
+			}
+		}
+	}
+
+	log.Printf("mocket: starting on (%s)...\n", config.Port)
+	http.ListenAndServe(":"+config.Port, nil)
+}
+
+// watch starts reloading the server's scripts as they change.
+func watch(server *Server, config *Config) {
+	interval, err := time.ParseDuration(config.Watch)
+	if err != nil || interval <= 0 {
+		log.Fatalf("mocket: invalid watch interval (%s)", config.Watch)
+	}
+
+	log.Printf("mocket: watching (%s) every (%s)...\n", config.ScriptDir,
+		interval)
+	go server.Watch(interval)
+}
+
+// seed makes the random values in responses repeatable.
+func seed(config *Config) {
+	n, err := strconv.ParseInt(config.Seed, 10, 64)
+	if err != nil {
+		log.Fatalf("mocket: invalid seed (%s)", config.Seed)
 	}
+
+	log.Printf("mocket: seeding random values with (%d)\n", n)
+	router.SeedRandom(n)
 }
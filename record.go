@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/infinadam/mocket/router"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Recorder proxies every request to an upstream and writes each exchange into
+// the script directory as a mocking script.
+type Recorder struct {
+	dir    string
+	strip  []string
+	dedupe bool
+	proxy  *router.Passthrough
+	lock   sync.Mutex
+}
+
+type exchangeKey struct{}
+
+// exchange is the incoming request, carried through the proxy so the recorded
+// script describes what the client sent rather than what went upstream.
+type exchange struct {
+	req  *http.Request
+	body []byte
+}
+
+func MakeRecorder(config *Config) (*Recorder, error) {
+	var err error
+	recorder := &Recorder{dir: config.ScriptDir, dedupe: config.RecordDedupe}
+
+	for _, h := range strings.Split(config.RecordStrip, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			recorder.strip = append(recorder.strip, h)
+		}
+	}
+
+	if err = os.MkdirAll(recorder.dir, 0755); err != nil {
+		return nil, err
+	}
+
+	if recorder.proxy, err = router.NewPassthrough(config.Record); err != nil {
+		return nil, err
+	}
+	recorder.proxy.Observe(recorder.record)
+
+	return recorder, nil
+}
+
+var unsafeName = regexp.MustCompile(`[^[:alnum:]._-]+`)
+
+// name gives the file an exchange is recorded to.  Deduplicated recordings are
+// named for a hash of the request, so repeats land on the same script;
+// otherwise each recording takes the next free number.
+func (r *Recorder) name(ex *exchange) (string, error) {
+	slug := strings.Trim(unsafeName.ReplaceAllString(ex.req.URL.Path, "_"), "_")
+	if slug == "" {
+		slug = "index"
+	}
+	slug = strings.ToLower(ex.req.Method) + "_" + slug
+
+	if r.dedupe {
+		hash := sha1.New()
+		fmt.Fprintf(hash, "%s %s\n", ex.req.Method, ex.req.URL.RequestURI())
+		hash.Write(ex.body)
+		sum := hex.EncodeToString(hash.Sum(nil))[:8]
+		return filepath.Join(r.dir, slug+"_"+sum+".json"), nil
+	}
+
+	for i := 1; ; i++ {
+		name := filepath.Join(r.dir, fmt.Sprintf("%s_%d.json", slug, i))
+		if _, err := os.Stat(name); errors.Is(err, fs.ErrNotExist) {
+			return name, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+func (r *Recorder) save(ex *exchange, script []byte) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	name, err := r.name(ex)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(name); err == nil {
+		return nil
+	}
+
+	log.Printf("mocket: recording %s %s (%s)\n", ex.req.Method,
+		ex.req.URL.RequestURI(), name)
+	return os.WriteFile(name, script, 0644)
+}
+
+func (r *Recorder) record(res *http.Response) error {
+	ex, ok := res.Request.Context().Value(exchangeKey{}).(*exchange)
+	if !ok {
+		return nil
+	}
+
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	script, err := router.ScriptFromExchange(ex.req, ex.body, res, body, r.strip)
+	if err == nil {
+		err = r.save(ex, script)
+	}
+	if err != nil {
+		log.Printf("mocket: error recording %s (%v)\n", ex.req.URL, err)
+	}
+
+	return nil
+}
+
+func (r *Recorder) HandleRequest(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(400)
+		return
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	// let the transport negotiate compression itself, so recorded bodies are
+	// stored decoded.
+	req.Header.Del("Accept-Encoding")
+
+	ex := &exchange{req, body}
+	req = req.WithContext(context.WithValue(req.Context(), exchangeKey{}, ex))
+	w.Header().Set(MockedHeader, Proxied)
+	r.proxy.Forward(w, req)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// upstream answers every request with its method and path, and a date that
+// changes, so that recordings have something to strip.
+func upstream(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			w.Header().Set("Date", "Mon, 01 Jan 2024 00:00:00 GMT")
+			w.Header().Set("X-Path", req.URL.Path)
+			w.WriteHeader(201)
+			w.Write([]byte(req.Method + " " + req.URL.Path + " " +
+				string(body)))
+		}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func record(recorder *Recorder, method string, url string, body string) {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	recorder.HandleRequest(httptest.NewRecorder(), req)
+}
+
+func recordings(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// should number each recording, naming it for the request
+func TestRecorderNames(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := MakeRecorder(&Config{ScriptDir: dir,
+		Record: upstream(t).URL})
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	record(recorder, "GET", "/", "")
+	record(recorder, "GET", "/users/42", "")
+	record(recorder, "GET", "/users/42", "")
+	record(recorder, "POST", "/a%20b/c.json", "x")
+
+	expected := []string{"get_index_1.json", "get_users_42_1.json",
+		"get_users_42_2.json", "post_a_b_c.json_1.json"}
+	if names := recordings(t, dir); strings.Join(names, " ") !=
+		strings.Join(expected, " ") {
+		t.Errorf("expected %v, received %v", expected, names)
+	}
+}
+
+// should record identical requests to one script when deduplicating
+func TestRecorderDedupe(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := MakeRecorder(&Config{ScriptDir: dir,
+		Record: upstream(t).URL, RecordDedupe: true})
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	record(recorder, "POST", "/orders", "1")
+	record(recorder, "POST", "/orders", "1")
+	record(recorder, "POST", "/orders", "2")
+	record(recorder, "POST", "/orders?page=2", "1")
+
+	if names := recordings(t, dir); len(names) != 3 {
+		t.Errorf("expected 3 recordings, received %v", names)
+	}
+}
+
+// should replay what it recorded, leaving out stripped headers
+func TestRecorderReplay(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := MakeRecorder(&Config{ScriptDir: dir,
+		Record: upstream(t).URL, RecordStrip: "date, x-missing"})
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	requests := []struct{ method, url, body string }{
+		{"GET", "/", ""},
+		{"GET", "/users/", ""},
+		{"GET", "/files/my%20doc?v=1", ""},
+		{"POST", "/orders", "1"},
+	}
+	for _, r := range requests {
+		record(recorder, r.method, r.url, r.body)
+	}
+
+	server, err := MakeServer(&Config{ScriptDir: dir})
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	for _, r := range requests {
+		w := httptest.NewRecorder()
+		server.HandleRequest(w, httptest.NewRequest(r.method, r.url,
+			strings.NewReader(r.body)))
+
+		path := strings.SplitN(r.url, "?", 2)[0]
+		path = strings.ReplaceAll(path, "%20", " ")
+		expected := r.method + " " + path + " " + r.body
+		if w.Code != 201 || w.Body.String() != expected {
+			t.Errorf("%s %s: expected 201 %q, received %d %q", r.method,
+				r.url, expected, w.Code, w.Body.String())
+		}
+		if w.Header().Get("Date") != "" || w.Header().Get("X-Path") == "" {
+			t.Errorf("%s %s: unexpected headers %v", r.method, r.url,
+				w.Header())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	server.HandleRequest(w, httptest.NewRequest("POST", "/orders",
+		strings.NewReader("12345")))
+	if w.Code != 404 {
+		t.Errorf("expected a different body not to replay, received %d",
+			w.Code)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "get_index_1.json")); err != nil {
+		t.Errorf("expected a recording of the root (%v)", err)
+	}
+}
@@ -0,0 +1,99 @@
+package router
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// responseBody takes the response body from whichever of "body" (sent as
+// JSON), "bodyText" (sent verbatim), "bodyBase64" (decoded and sent verbatim)
+// and "bodyFile" (streamed from disk) the script gives.  Text bodies and the
+// strings in JSON bodies are templates; the others are sent as they are.
+func responseBody(action *HTTPAction, parsed *httpJSON) error {
+	var err error
+	r := &action.Response
+	p := &parsed.Response
+
+	given := 0
+	for _, ok := range []bool{p.Body != nil, p.BodyText != nil,
+		p.BodyBase64 != "", p.BodyFile != ""} {
+		if ok {
+			given++
+		}
+	}
+	if given > 1 {
+		return errors.New("response has more than one body")
+	}
+
+	switch {
+	case p.BodyText != nil:
+		r.Body = []byte(*p.BodyText)
+		r.body, err = compileTemplate("body", *p.BodyText)
+	case p.BodyBase64 != "":
+		r.Body, err = base64.StdEncoding.DecodeString(p.BodyBase64)
+	case p.BodyFile != "":
+		r.File = p.BodyFile
+	default:
+		var templated bool
+		r.Body, _ = json.Marshal(p.Body)
+		if r.document, templated, err = compileDocument(p.Body); !templated {
+			r.document = nil
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+	return nil
+}
+
+// openBody gives the body to send in reply to a request, and its length.
+func (a *HTTPAction) openBody(w http.ResponseWriter, data *TemplateData) (
+	io.ReadCloser, int64, error) {
+	r := &a.Response
+
+	switch {
+	case r.File != "":
+		f, err := os.Open(r.File)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+
+		if w.Header().Get("Content-Type") == "" {
+			if t := mime.TypeByExtension(filepath.Ext(r.File)); t != "" {
+				w.Header().Set("Content-Type", t)
+			}
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+		return f, info.Size(), nil
+	case r.document != nil:
+		body, err := renderDocument(r.document, data)
+		if err != nil {
+			return nil, 0, err
+		}
+		return io.NopCloser(bytes.NewReader(body)), int64(len(body)), nil
+	case r.body != nil:
+		body, err := render(r.body, data)
+		if err != nil {
+			return nil, 0, err
+		}
+		return io.NopCloser(bytes.NewReader(body)), int64(len(body)), nil
+	default:
+		return io.NopCloser(bytes.NewReader(r.Body)), int64(len(r.Body)), nil
+	}
+}
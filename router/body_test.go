@@ -0,0 +1,85 @@
+package router
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// should send a text body verbatim, rendering it as a template
+func TestBodyText(t *testing.T) {
+	result := templateScript(t, `{
+		"status": 200,
+		"bodyText": "id: {{.Vars.id}}\n\"quoted\"\n"
+	}`)
+
+	w := renderAction(result, "", map[string]string{"id": "7"})
+	if body := w.Body.String(); body != "id: 7\n\"quoted\"\n" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+// should decode a base64 body and send its bytes as they are
+func TestBodyBase64(t *testing.T) {
+	result := templateScript(t, `{
+		"status": 200,
+		"headers": { "content-type": "image/png" },
+		"bodyBase64": "iVBORwD/e3t9fQ=="
+	}`)
+
+	w := renderAction(result, "", nil)
+	expected := []byte("\x89PNG\x00\xff{{}}")
+	if !bytes.Equal(w.Body.Bytes(), expected) {
+		t.Errorf("expected body %q, received %q", expected, w.Body.Bytes())
+	}
+}
+
+// should stream a body from a file, with its length and content type
+func TestBodyFile(t *testing.T) {
+	content := bytes.Repeat([]byte("\x00\x01{{binary}}"), 1024)
+	file := filepath.Join(t.TempDir(), "blob.png")
+	if err := os.WriteFile(file, content, 0o644); err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	result := templateScript(t, `{ "status": 200, "bodyFile": `+
+		strconv.Quote(file)+` }`)
+
+	w := renderAction(result, "", nil)
+	if !bytes.Equal(w.Body.Bytes(), content) {
+		t.Errorf("expected the file's %d bytes, received %d", len(content),
+			w.Body.Len())
+	}
+	if length := w.Header().Get("Content-Length"); length !=
+		strconv.Itoa(len(content)) {
+		t.Errorf("expected length %d, received %q", len(content), length)
+	}
+	if kind := w.Header().Get("Content-Type"); kind != "image/png" {
+		t.Errorf("expected type \"image/png\", received %q", kind)
+	}
+}
+
+// should answer with a 500 if the body's file has gone
+func TestBodyFileMissing(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "missing.txt")
+	result := templateScript(t, `{ "status": 200, "bodyFile": `+
+		strconv.Quote(file)+` }`)
+
+	if w := renderAction(result, "", nil); w.Code != 500 {
+		t.Errorf("expected status 500, received %d", w.Code)
+	}
+}
+
+// should refuse a response with more than one body
+func TestBodyMoreThanOne(t *testing.T) {
+	_, err := HTTPActionFromJSON([]byte(`{
+		"request": { "method": "get", "url": "/items" },
+		"response": { "status": 200, "body": {}, "bodyText": "text" }
+	}`))
+
+	if err == nil {
+		t.Error("expected an error, received none")
+	}
+}
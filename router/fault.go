@@ -0,0 +1,101 @@
+package router
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// the ways a response can drop its connection.
+const (
+	DropBeforeHeaders = "before-headers"
+	DropMidBody       = "mid-body"
+)
+
+// Faults describe how a mocked response misbehaves: how long it waits before
+// answering, whether it answers at all, and whether it drops the connection
+// part-way through.
+type Faults struct {
+	Delay    time.Duration
+	DelayMax time.Duration
+	Hang     bool
+	Drop     string
+}
+
+func responseFaults(action *HTTPAction, parsed *httpJSON) error {
+	var err error
+	f := &action.Response.Faults
+	res := &parsed.Response
+
+	if res.Delay != "" {
+		if f.Delay, err = time.ParseDuration(res.Delay); err != nil {
+			return err
+		}
+	}
+
+	if res.DelayMax != "" {
+		if f.DelayMax, err = time.ParseDuration(res.DelayMax); err != nil {
+			return err
+		} else if f.DelayMax < f.Delay {
+			return errors.New("delayMax is shorter than delay")
+		}
+	}
+
+	switch res.Drop {
+	case "", DropBeforeHeaders, DropMidBody:
+		f.Drop = res.Drop
+	default:
+		return errors.New("unrecognized drop")
+	}
+
+	f.Hang = res.Hang
+	return nil
+}
+
+// wait holds the response back for its delay, or forever if it hangs.  It
+// returns false if the client gave up first.
+func (f *Faults) wait(req *http.Request) bool {
+	done := req.Context().Done()
+	if f.Hang {
+		<-done
+		return false
+	}
+
+	delay := f.Delay
+	if f.DelayMax > f.Delay {
+		delay += time.Duration(rand.Int63n(int64(f.DelayMax - f.Delay)))
+	}
+	if delay == 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// drop closes the client's connection out from under it, first sending
+// anything already written if flush is set.
+func drop(w http.ResponseWriter, flush bool) {
+	if f, ok := w.(http.Flusher); ok && flush {
+		f.Flush()
+	}
+
+	if h, ok := w.(http.Hijacker); ok {
+		if conn, _, err := h.Hijack(); err == nil {
+			conn.Close()
+			return
+		}
+	}
+
+	// connections that can't be hijacked, such as HTTP/2 streams, are reset
+	// by the server when a handler aborts.
+	panic(http.ErrAbortHandler)
+}
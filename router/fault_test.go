@@ -0,0 +1,141 @@
+package router
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func faultyServer(t *testing.T, script string) *httptest.Server {
+	action := parseAction(t, script)
+	return httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			action.Write(w, req, nil)
+		}))
+}
+
+// should correctly parse response faults
+func TestHTTPActionFaults(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get"
+		},
+		"response": {
+			"delay": "1s",
+			"delayMax": "3s",
+			"hang": true,
+			"drop": "mid-body"
+		}
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	f := result.Response.Faults
+	expected := Faults{time.Second, 3 * time.Second, true, DropMidBody}
+	if f != expected {
+		t.Errorf("expected %+v, received %+v", expected, f)
+	}
+}
+
+// should return an error for an unrecognized drop
+func TestHTTPActionFaultsDropError(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get"
+		},
+		"response": {
+			"drop": "sometimes"
+		}
+	}`))
+
+	if err == nil {
+		t.Error("error should not be nil")
+	}
+
+	if result != nil {
+		t.Error("expected result to be nil")
+	}
+}
+
+// should wait out a delay before responding
+func TestFaultsDelay(t *testing.T) {
+	server := faultyServer(t, `{
+		"request": { "method": "get" },
+		"response": { "status": 200, "delay": "50ms" }
+	}`)
+	defer server.Close()
+
+	start := time.Now()
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+	res.Body.Close()
+
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected a delay of at least 50ms, got %v", elapsed)
+	}
+}
+
+// should never respond when hanging
+func TestFaultsHang(t *testing.T) {
+	server := faultyServer(t, `{
+		"request": { "method": "get" },
+		"response": { "status": 200, "hang": true }
+	}`)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+	if _, err := http.DefaultClient.Do(req); err == nil {
+		t.Error("expected the request to time out")
+	}
+}
+
+// should close the connection before sending headers
+func TestFaultsDropBeforeHeaders(t *testing.T) {
+	server := faultyServer(t, `{
+		"request": { "method": "get" },
+		"response": { "status": 200, "drop": "before-headers" }
+	}`)
+	defer server.Close()
+
+	if _, err := http.Get(server.URL); err == nil {
+		t.Error("expected the connection to be dropped")
+	}
+}
+
+// should close the connection halfway through the body
+func TestFaultsDropMidBody(t *testing.T) {
+	server := faultyServer(t, `{
+		"request": { "method": "get" },
+		"response": { "status": 200, "body": "0123456789", "drop": "mid-body" }
+	}`)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		t.Errorf("expected status to be 200, got %d", res.StatusCode)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err == nil {
+		t.Error("expected the body to be cut short")
+	}
+	if string(body) != `"01234` {
+		t.Errorf("expected half the body, received %q", body)
+	}
+}
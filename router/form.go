@@ -0,0 +1,195 @@
+package router
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// Form matches a request body submitted as a form, either url encoded or
+// multipart.  Fields match like a query string, and Files, which require a
+// multipart body, match the files uploaded alongside them.
+type Form struct {
+	Fields []Query
+	Files  []FormFile
+}
+
+// FormFile matches a file uploaded in the form field Field.  Its name and
+// content type must match, and its size in bytes must be within MinSize and
+// MaxSize, unless those are zero.
+type FormFile struct {
+	Field       string
+	Filename    *regexp.Regexp
+	ContentType *regexp.Regexp
+	MinSize     int64
+	MaxSize     int64
+}
+
+type formFileJSON struct {
+	Filename    string `json:"filename,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	MinSize     int64  `json:"minSize,omitempty"`
+	MaxSize     int64  `json:"maxSize,omitempty"`
+}
+
+// upload is a file found in a multipart body.
+type upload struct {
+	field       string
+	filename    string
+	contentType string
+	size        int64
+}
+
+func requestForm(action *HTTPAction, parsed *httpJSON) error {
+	if parsed.Request.Form == nil && parsed.Request.Files == nil {
+		return nil
+	}
+
+	var err error
+	form := new(Form)
+	if form.Fields, err = queryMatchers(parsed.Request.Form); err != nil {
+		return err
+	}
+
+	for _, field := range sortedKeys(parsed.Request.Files) {
+		spec := parsed.Request.Files[field]
+		f := FormFile{Field: field, MinSize: spec.MinSize,
+			MaxSize: spec.MaxSize}
+
+		if f.MaxSize != 0 && f.MaxSize < f.MinSize {
+			return fmt.Errorf("file %q has a maxSize below its minSize", field)
+		}
+		if f.Filename, err = compilePattern(spec.Filename, nil,
+			spec.Filename == ""); err != nil {
+			return err
+		}
+		if f.ContentType, err = compilePattern(spec.ContentType, nil,
+			spec.ContentType == ""); err != nil {
+			return err
+		}
+
+		form.Files = append(form.Files, f)
+	}
+
+	action.Request.Form = form
+	return nil
+}
+
+// parseForm decodes a form body according to its content type.
+func parseForm(contentType string, body []byte) (url.Values, []upload,
+	error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		return values, nil, err
+	case "multipart/form-data":
+	default:
+		return nil, nil, fmt.Errorf("%q is not a form", mediaType)
+	}
+
+	values := make(url.Values)
+	var uploads []upload
+	r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(part)
+			if err != nil {
+				return nil, nil, err
+			}
+			values.Add(part.FormName(), string(value))
+			continue
+		}
+
+		size, err := io.Copy(io.Discard, part)
+		if err != nil {
+			return nil, nil, err
+		}
+		uploads = append(uploads, upload{
+			field:       part.FormName(),
+			filename:    part.FileName(),
+			contentType: part.Header.Get("Content-Type"),
+			size:        size,
+		})
+	}
+
+	return values, uploads, nil
+}
+
+// compare checks a script's file against the uploads, returning the captures
+// from the first that matches.
+func (f *FormFile) compare(uploads []upload) (bool, map[string]string) {
+	for _, u := range uploads {
+		if u.field != f.Field || u.size < f.MinSize ||
+			f.MaxSize != 0 && u.size > f.MaxSize {
+			continue
+		}
+
+		matched, vars := match(f.Filename, u.filename)
+		if !matched {
+			continue
+		}
+		if matched, groups := match(f.ContentType, u.contentType); matched {
+			vars[f.Field] = u.filename
+			return true, merge(vars, groups)
+		}
+	}
+
+	return false, nil
+}
+
+// CompareForm matches the request body as a form, if the script has one.
+// The first value of each field the script names is captured under the
+// field's name, as is the name of each file it expects.
+func (a *HTTPAction) CompareForm(req *http.Request, body []byte) (bool,
+	map[string]string) {
+	form := a.Request.Form
+	if form == nil {
+		return true, nil
+	}
+
+	values, uploads, err := parseForm(req.Header.Get("Content-Type"), body)
+	if err != nil {
+		return false, nil
+	}
+
+	vars := make(map[string]string)
+	for _, q := range form.Fields {
+		if v, ok := values[q.Key]; ok {
+			vars[q.Key] = v[0]
+		}
+	}
+
+	matched, groups := compareValues(form.Fields, values)
+	if !matched {
+		return false, nil
+	}
+	merge(vars, groups)
+
+	for _, f := range form.Files {
+		if matched, groups := f.compare(uploads); !matched {
+			return false, nil
+		} else {
+			merge(vars, groups)
+		}
+	}
+
+	return true, vars
+}
@@ -0,0 +1,108 @@
+package router
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func formRequest(contentType string) *http.Request {
+	return &http.Request{Header: http.Header{"Content-Type": {contentType}}}
+}
+
+// should match url encoded fields in any order and capture their values
+func TestCompareFormURLEncoded(t *testing.T) {
+	result := requestAction(t, "post", `"form": {
+		"grant_type": "client_credentials",
+		"scope": { "regex": "read|write", "optional": true },
+		"client_id": "{{/(?<client>\\w+)/}}"
+	}`)
+	req := formRequest("application/x-www-form-urlencoded")
+
+	tests := []struct {
+		body     string
+		expected bool
+	}{
+		{"client_id=abc&grant_type=client_credentials", true},
+		{"grant_type=client_credentials&scope=read&client_id=abc", true},
+		{"grant_type=client_credentials&scope=admin&client_id=abc", false},
+		{"grant_type=password&client_id=abc", false},
+		{"grant_type=client_credentials", false},
+	}
+
+	for _, test := range tests {
+		matched, vars := result.CompareForm(req, []byte(test.body))
+		if matched != test.expected {
+			t.Errorf("expected %v for %q, got %v", test.expected, test.body,
+				matched)
+		}
+		if matched && (vars["client"] != "abc" || vars["client_id"] != "abc" ||
+			vars["grant_type"] != "client_credentials") {
+			t.Errorf("unexpected captures %v", vars)
+		}
+	}
+
+	if matched, _ := result.CompareForm(formRequest("application/json"),
+		[]byte("grant_type=client_credentials&client_id=abc")); matched {
+		t.Error("expected a body that isn't a form not to match")
+	}
+}
+
+// should match multipart fields and files by name, type and size
+func TestCompareFormMultipart(t *testing.T) {
+	result := requestAction(t, "post", `"form": { "title": "report" },
+		"files": {
+			"upload": {
+				"filename": "{{/(?<name>.+)\\.pdf/}}",
+				"contentType": "application/pdf",
+				"maxSize": 8
+			}
+		}`)
+
+	multipartBody := func(filename, contentType, content string) (
+		*http.Request, []byte) {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		w.WriteField("title", "report")
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition",
+			`form-data; name="upload"; filename="`+filename+`"`)
+		header.Set("Content-Type", contentType)
+		part, _ := w.CreatePart(header)
+		part.Write([]byte(content))
+		w.Close()
+
+		return formRequest(w.FormDataContentType()), body.Bytes()
+	}
+
+	req, body := multipartBody("q3.pdf", "application/pdf", "%PDF-1")
+	matched, vars := result.CompareForm(req, body)
+	if !matched {
+		t.Fatal("expected form to match")
+	}
+	if vars["name"] != "q3" || vars["upload"] != "q3.pdf" ||
+		vars["title"] != "report" {
+		t.Errorf("unexpected captures %v", vars)
+	}
+
+	for _, args := range [][]string{
+		{"q3.png", "application/pdf", "%PDF-1"},
+		{"q3.pdf", "image/png", "%PDF-1"},
+		{"q3.pdf", "application/pdf", strings.Repeat("x", 9)},
+	} {
+		req, body := multipartBody(args[0], args[1], args[2])
+		if matched, _ := result.CompareForm(req, body); matched {
+			t.Errorf("expected %v not to match", args)
+		}
+	}
+
+	if matched, _ := result.CompareForm(
+		formRequest("application/x-www-form-urlencoded"),
+		[]byte("title=report")); matched {
+		t.Error("expected a form without files not to match")
+	}
+}
@@ -0,0 +1,59 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"path"
+	"strings"
+)
+
+// ScriptToJSON converts a script to JSON according to the extension of its
+// file name: ".yaml" and ".yml" scripts are YAML, ".toml" scripts are TOML,
+// and anything else is taken to be JSON already.  Every format describes the
+// same script, so the result can be given to HTTPActionFromJSON or
+// TCPScriptFromJSON.
+func ScriptToJSON(name string, script []byte) ([]byte, error) {
+	var doc any
+
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(script, &doc); err != nil {
+			return nil, err
+		}
+	case ".toml":
+		var table map[string]any
+		if err := toml.Unmarshal(script, &table); err != nil {
+			return nil, err
+		}
+		doc = table
+	default:
+		return script, nil
+	}
+
+	return json.Marshal(stringKeys(doc))
+}
+
+// stringKeys converts the maps YAML decodes with keys that aren't strings,
+// such as status codes, into maps JSON can encode.
+func stringKeys(v any) any {
+	switch node := v.(type) {
+	case map[string]any:
+		for k, child := range node {
+			node[k] = stringKeys(child)
+		}
+	case map[any]any:
+		converted := make(map[string]any)
+		for k, child := range node {
+			converted[fmt.Sprint(k)] = stringKeys(child)
+		}
+		return converted
+	case []any:
+		for i, child := range node {
+			node[i] = stringKeys(child)
+		}
+	}
+
+	return v
+}
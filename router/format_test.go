@@ -0,0 +1,85 @@
+package router
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// should convert YAML and TOML scripts to the same JSON
+func TestScriptToJSON(t *testing.T) {
+	expected := map[string]any{
+		"request": map[string]any{
+			"method": "get",
+			"url":    `/users/{{/\d+/}}`,
+		},
+		"response": map[string]any{
+			"status": 200.0,
+			"body":   "line one\nline two\n",
+		},
+		"labels": map[string]any{"404": "missing"},
+	}
+
+	scripts := map[string]string{
+		"script.yaml": `
+# comments are allowed
+request:
+  method: get
+  url: /users/{{/\d+/}}
+response:
+  status: 200
+  body: |
+    line one
+    line two
+labels:
+  404: missing
+`,
+		"script.TOML": `
+# comments are allowed
+[request]
+method = "get"
+url = '/users/{{/\d+/}}'
+
+[response]
+status = 200
+body = """
+line one
+line two
+"""
+
+[labels]
+404 = "missing"
+`,
+	}
+
+	for name, script := range scripts {
+		converted, err := ScriptToJSON(name, []byte(script))
+		if err != nil {
+			t.Fatalf("received error for %s (%v)", name, err)
+		}
+
+		var result map[string]any
+		json.Unmarshal(converted, &result)
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("expected %v for %s, received %v", expected, name, result)
+		}
+	}
+}
+
+// should leave other scripts as they are
+func TestScriptToJSONOther(t *testing.T) {
+	script := []byte(`{ "request": {} }`)
+	if converted, _ := ScriptToJSON("script.json", script); string(converted) !=
+		string(script) {
+		t.Errorf("expected %s, received %s", script, converted)
+	}
+}
+
+// should return an error for a malformed script
+func TestScriptToJSONError(t *testing.T) {
+	for _, name := range []string{"script.yml", "script.toml"} {
+		if _, err := ScriptToJSON(name, []byte("a: [b\n= c")); err == nil {
+			t.Errorf("expected an error for %s", name)
+		}
+	}
+}
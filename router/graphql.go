@@ -0,0 +1,324 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// GraphQLMatcher compares a request body as a GraphQL operation.  The
+// operation's name must match OperationName, its top-level selection must
+// include every one of Fields, and its variables must satisfy Variables.
+type GraphQLMatcher struct {
+	OperationName *regexp.Regexp
+	Fields        []string
+	Variables     *JSONMatcher
+}
+
+type graphqlJSON struct {
+	OperationName *string         `json:"operationName,omitempty"`
+	Fields        []string        `json:"fields,omitempty"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+}
+
+// graphqlOperation is an operation defined in a GraphQL document.
+type graphqlOperation struct {
+	name   string
+	fields []string
+}
+
+// graphqlDefaults makes a GraphQL script a POST to /graphql, unless it says
+// otherwise.  It runs before the request is parsed.
+func graphqlDefaults(action *HTTPAction, parsed *httpJSON) error {
+	if parsed.GraphQL == nil {
+		return nil
+	}
+
+	if parsed.Request.Method == "" {
+		parsed.Request.Method = "post"
+	}
+	if parsed.Request.URL == "" && parsed.Request.Path == "" {
+		parsed.Request.URL = "/graphql"
+	}
+	return nil
+}
+
+func requestGraphQL(action *HTTPAction, parsed *httpJSON) error {
+	spec := parsed.GraphQL
+	if spec == nil {
+		return nil
+	}
+
+	var err error
+	m := &GraphQLMatcher{Fields: spec.Fields}
+	if spec.OperationName != nil {
+		m.OperationName, err = compilePattern(*spec.OperationName, nil, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	// variables are either a partial document or a list of predicates.
+	if v := bytes.TrimSpace(spec.Variables); len(v) > 0 && v[0] == '[' {
+		var predicates []jsonPredicateJSON
+		if err := json.Unmarshal(v, &predicates); err != nil {
+			return err
+		}
+
+		m.Variables = new(JSONMatcher)
+		for i := range predicates {
+			if p, err := jsonPredicate(&predicates[i]); err != nil {
+				return err
+			} else {
+				m.Variables.Predicates = append(m.Variables.Predicates, p)
+			}
+		}
+	} else if len(v) > 0 {
+		var doc any
+		if err := json.Unmarshal(v, &doc); err != nil {
+			return err
+		}
+
+		m.Variables = new(JSONMatcher)
+		if m.Variables.Document, err = compileJSON(doc); err != nil {
+			return err
+		}
+	}
+
+	action.Request.GraphQL = m
+	return nil
+}
+
+// graphqlResponse wraps a GraphQL script's data and errors in the envelope
+// GraphQL clients expect, unless it gives its own body.
+func graphqlResponse(action *HTTPAction, parsed *httpJSON) error {
+	if parsed.GraphQL == nil || parsed.Response.Body != nil {
+		return nil
+	}
+
+	envelope := map[string]any{"data": parsed.Response.Data}
+	if parsed.Response.Errors != nil {
+		envelope["errors"] = parsed.Response.Errors
+	}
+	parsed.Response.Body = envelope
+
+	if parsed.Response.Status == 0 {
+		parsed.Response.Status = 200
+	}
+	if parsed.Response.Headers == nil {
+		parsed.Response.Headers = make(map[string]string)
+	}
+	for k := range parsed.Response.Headers {
+		if strings.EqualFold(k, "content-type") {
+			return nil
+		}
+	}
+	parsed.Response.Headers["content-type"] = "application/json"
+	return nil
+}
+
+// graphqlTokens splits a GraphQL document into names, punctuation and
+// strings, dropping whitespace, commas and comments.
+func graphqlTokens(document string) []string {
+	var tokens []string
+	s := document
+
+	for s != "" {
+		r := rune(s[0])
+		switch {
+		case r == '#':
+			if end := strings.IndexByte(s, '\n'); end >= 0 {
+				s = s[end:]
+			} else {
+				s = ""
+			}
+		case r == ',' || unicode.IsSpace(r):
+			s = s[1:]
+		case strings.HasPrefix(s, `"""`):
+			end := strings.Index(s[3:], `"""`)
+			if end < 0 {
+				end = len(s) - 6
+			}
+			tokens, s = append(tokens, s[:end+6]), s[end+6:]
+		case r == '"':
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(s))
+			tokens, s = append(tokens, s[:end]), s[end:]
+		case strings.HasPrefix(s, "..."):
+			tokens, s = append(tokens, "..."), s[3:]
+		case r == '_' || r == '-' || r == '.' || r < unicode.MaxASCII &&
+			(unicode.IsLetter(r) || unicode.IsDigit(r)):
+			end := strings.IndexFunc(s, func(r rune) bool {
+				return r != '_' && r != '-' && r != '.' &&
+					!unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+			if end < 0 {
+				end = len(s)
+			}
+			tokens, s = append(tokens, s[:end]), s[end:]
+		default:
+			tokens, s = append(tokens, s[:1]), s[1:]
+		}
+	}
+
+	return tokens
+}
+
+// skipBlock skips past the balanced open and close tokens starting at
+// tokens[i], returning the index after them.
+func skipBlock(tokens []string, i int, open string, close string) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		if tokens[i] == open {
+			depth++
+		} else if tokens[i] == close {
+			if depth--; depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+// selection gives the names of the fields in the selection set starting at
+// tokens[i], and the index after it.  Fragments aren't expanded.
+func selection(tokens []string, i int) ([]string, int) {
+	var fields []string
+	end := skipBlock(tokens, i, "{", "}")
+
+	for i++; i < end-1; {
+		switch t := tokens[i]; {
+		case t == "{":
+			i = skipBlock(tokens, i, "{", "}")
+		case t == "(":
+			i = skipBlock(tokens, i, "(", ")")
+		case t == "@":
+			i += 2
+		case t == "...":
+			// skip the fragment's name, or the type of an inline fragment.
+			if i++; i < end && tokens[i] == "on" {
+				i += 2
+			} else if i < end && tokens[i] != "{" && tokens[i] != "@" {
+				i++
+			}
+		case i+1 < end && tokens[i+1] == ":":
+			// a query cut off after an alias has no field to name.
+			if i+2 < end {
+				fields = append(fields, tokens[i+2])
+			}
+			i += 3
+		case t == "}":
+			i++
+		default:
+			fields = append(fields, t)
+			i++
+		}
+	}
+
+	return fields, end
+}
+
+// graphqlOperations finds the operations a GraphQL document defines.
+func graphqlOperations(document string) []graphqlOperation {
+	var operations []graphqlOperation
+	tokens := graphqlTokens(document)
+
+	for i := 0; i < len(tokens); {
+		var op graphqlOperation
+
+		switch tokens[i] {
+		case "{":
+		case "query", "mutation", "subscription":
+			if i++; i < len(tokens) && tokens[i] != "{" && tokens[i] != "(" &&
+				tokens[i] != "@" {
+				op.name = tokens[i]
+			}
+			for i < len(tokens) && tokens[i] != "{" {
+				if tokens[i] == "(" {
+					i = skipBlock(tokens, i, "(", ")")
+				} else {
+					i++
+				}
+			}
+		default:
+			// fragment definitions and anything unrecognized.
+			for i < len(tokens) && tokens[i] != "{" {
+				i++
+			}
+			i = skipBlock(tokens, i, "{", "}")
+			continue
+		}
+
+		op.fields, i = selection(tokens, i)
+		operations = append(operations, op)
+	}
+
+	return operations
+}
+
+// Match decodes body as a GraphQL request and compares it against the
+// matcher, returning the captures made along the way.
+func (m *GraphQLMatcher) Match(body []byte) (bool, map[string]string) {
+	var req struct {
+		Query         string          `json:"query"`
+		OperationName string          `json:"operationName"`
+		Variables     json.RawMessage `json:"variables"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return false, nil
+	}
+
+	// the request names the operation to run, unless there's only one.
+	var op *graphqlOperation
+	operations := graphqlOperations(req.Query)
+	for i := range operations {
+		if operations[i].name == req.OperationName ||
+			req.OperationName == "" && len(operations) == 1 {
+			op = &operations[i]
+		}
+	}
+	if op == nil {
+		return false, nil
+	}
+
+	vars := make(map[string]string)
+	if m.OperationName != nil {
+		if matched, groups := match(m.OperationName, op.name); !matched {
+			return false, nil
+		} else {
+			merge(vars, groups)
+		}
+	}
+
+	for _, f := range m.Fields {
+		found := false
+		for _, field := range op.fields {
+			found = found || field == f
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	if m.Variables != nil {
+		variables := req.Variables
+		if len(variables) == 0 {
+			variables = []byte("{}")
+		}
+		if matched, groups := m.Variables.Match(variables); !matched {
+			return false, nil
+		} else {
+			merge(vars, groups)
+		}
+	}
+
+	return true, vars
+}
@@ -0,0 +1,115 @@
+package router
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// should find each operation's name and top-level fields
+func TestGraphQLOperations(t *testing.T) {
+	document := `
+		# a comment { with braces }
+		query GetUser($id: ID!, $filter: Filter = { kind: "a" }) {
+			user(id: $id) { name posts(first: 2) { title } }
+			viewer: me @include(if: true) { id }
+			...Extra
+			... on Query { settings }
+		}
+		fragment Extra on Query { hidden }
+		mutation { rename(name: "}") { ok } }
+	`
+
+	expected := []graphqlOperation{
+		{"GetUser", []string{"user", "me"}},
+		{"", []string{"rename"}},
+	}
+
+	if operations := graphqlOperations(document); !reflect.DeepEqual(
+		operations, expected) {
+		t.Errorf("expected %v, received %v", expected, operations)
+	}
+}
+
+// should default a GraphQL script's route and wrap its response
+func TestHTTPActionGraphQL(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"graphql": { "operationName": "GetUser" },
+		"response": { "data": { "user": { "name": "ann" } } }
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	path := []string{"^post$", "^graphql$"}
+	if len(result.Request.Path) != len(path) {
+		t.Fatalf("expected %d segments, got %d", len(path),
+			len(result.Request.Path))
+	}
+	for i, re := range result.Request.Path {
+		if re.String() != path[i] {
+			t.Errorf("expected %q, received %q", path[i], re)
+		}
+	}
+
+	var body map[string]any
+	json.Unmarshal(result.Response.Body, &body)
+	if _, ok := body["data"]; !ok || len(body) != 1 {
+		t.Errorf("unexpected response body %s", result.Response.Body)
+	}
+	if result.Response.Status != 200 ||
+		result.Response.Headers["content-type"] != "application/json" {
+		t.Errorf("unexpected response %+v", result.Response)
+	}
+}
+
+// should match operations by name, fields and variables
+func TestCompareBodyGraphQL(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"graphql": {
+			"operationName": "{{/(?<op>Get\\w+)/}}",
+			"fields": ["user"],
+			"variables": [{ "path": "$.id", "regex": "\\d+", "as": "id" }]
+		},
+		"response": { "data": null, "errors": [{ "message": "nope" }] }
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	query := `query GetUser($id: ID!) { user(id: $id) { name } }`
+	tests := []struct {
+		operationName string
+		query         string
+		variables     string
+		expected      bool
+	}{
+		{"", query, `{"id": "42"}`, true},
+		{"GetUser", query + " query Other { user { id } }", `{"id": "42"}`, true},
+		{"Other", query + " query Other { user { id } }", `{"id": "42"}`, false},
+		{"", query, `{"id": "x"}`, false},
+		{"", query, `null`, false},
+		{"", `query GetUser { viewer { id } }`, `{"id": "42"}`, false},
+		{"", `query SetUser { user { id } }`, `{"id": "42"}`, false},
+		{"", `{ a:`, `{"id": "42"}`, false},
+		{"", `query GetUser { user: `, `{"id": "42"}`, false},
+	}
+
+	for _, test := range tests {
+		body, _ := json.Marshal(map[string]any{
+			"operationName": test.operationName,
+			"query":         test.query,
+			"variables":     json.RawMessage(test.variables),
+		})
+
+		matched, vars := result.CompareBody(string(body))
+		if matched != test.expected {
+			t.Errorf("expected %v for %s, got %v", test.expected, body, matched)
+		}
+		if matched && (vars["op"] != "GetUser" || vars["id"] != "42") {
+			t.Errorf("unexpected captures %v", vars)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Header matches the request headers whose names match Label.  Headers are
+// required unless they're Optional, in which case they may be missing but
+// must match when present.  An Absent header must not be sent at all, and a
+// Negate header must not be sent with a value that matches.
+type Header struct {
+	Label    *regexp.Regexp
+	Value    *regexp.Regexp
+	Optional bool
+	Absent   bool
+	Negate   bool
+}
+
+// headerMatcher parses one matcher from a script's headers: a regular
+// expression for the value, or an object giving the "value" along with any of
+// "optional", "absent", "not" and "partial".  Unless partial is set, names and
+// values must match in their entirety.
+func headerMatcher(label string, spec any) (Header, error) {
+	var err error
+	var value string
+	var partial bool
+	var h Header
+
+	switch v := spec.(type) {
+	case string:
+		value = v
+	case map[string]any:
+		value, _ = v["value"].(string)
+		partial, _ = v["partial"].(bool)
+		h.Optional, _ = v["optional"].(bool)
+		h.Absent, _ = v["absent"].(bool)
+		h.Negate, _ = v["not"].(bool)
+	default:
+		return h, fmt.Errorf("header %q has an unrecognized matcher", label)
+	}
+
+	// header names are case-insensitive, however the script writes them.
+	if h.Label, err = compile("(?i)"+label, false); err != nil {
+		return h, err
+	}
+	if h.Value, err = compile(value, partial); err != nil {
+		return h, err
+	}
+
+	return h, nil
+}
+
+func requestHeaders(action *HTTPAction, parsed *httpJSON) error {
+	var labels []string
+	for l := range parsed.Request.Headers {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+
+	for _, l := range labels {
+		if h, err := headerMatcher(l, parsed.Request.Headers[l]); err != nil {
+			return err
+		} else {
+			action.Request.Headers = append(action.Request.Headers, h)
+		}
+	}
+
+	return nil
+}
+
+// compare checks a single script header against the request's.
+func (h *Header) compare(header http.Header) (bool, map[string]string) {
+	var values []string
+	vars := make(map[string]string)
+	present := false
+
+	for l, v := range header {
+		if matched, groups := match(h.Label, strings.ToLower(l)); matched {
+			present = true
+			merge(vars, groups)
+			values = append(values, strings.Join(v, ","))
+		}
+	}
+
+	switch {
+	case h.Absent:
+		return !present, nil
+	case !present:
+		return h.Optional || h.Negate, nil
+	}
+
+	for _, v := range values {
+		if matched, groups := match(h.Value, v); matched && h.Negate {
+			return false, nil
+		} else if matched {
+			return true, merge(vars, groups)
+		}
+	}
+
+	return h.Negate, nil
+}
+
+// CompareHeaders matches the request's headers against every header the
+// script declares.  Header names are compared without regard to case.
+func (a *HTTPAction) CompareHeaders(header http.Header) (bool,
+	map[string]string) {
+	vars := make(map[string]string)
+
+	for _, h := range a.Request.Headers {
+		if matched, groups := h.compare(header); !matched {
+			return false, nil
+		} else {
+			merge(vars, groups)
+		}
+	}
+
+	return true, vars
+}
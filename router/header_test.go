@@ -0,0 +1,117 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+)
+
+// should correctly parse header matcher options
+func TestHTTPActionRequestHeaderOptions(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get",
+			"headers": {
+				"a": { "value": "x", "optional": true },
+				"b": { "absent": true },
+				"c": { "value": "y", "not": true }
+			}
+		}
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	headers := result.Request.Headers
+	if len(headers) != 3 {
+		t.Fatalf("expected 3 headers, got %d", len(headers))
+	}
+
+	if !headers[0].Optional || headers[0].Value.String() != "^(?:x)$" {
+		t.Error("did not correctly parse optional header")
+	}
+	if !headers[1].Absent {
+		t.Error("did not correctly parse absent header")
+	}
+	if !headers[2].Negate || headers[2].Value.String() != "^(?:y)$" {
+		t.Error("did not correctly parse negated header")
+	}
+}
+
+// should return an error for an unrecognized header matcher
+func TestHTTPActionRequestHeaderMatcherError(t *testing.T) {
+	_, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get",
+			"headers": { "a": 1 }
+		}
+	}`))
+
+	if err == nil {
+		t.Error("error should not be nil")
+	}
+}
+
+// should match headers according to their options
+func TestCompareHeaders(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get",
+			"headers": {
+				"authorization": "Bearer (?P<token>\\w+)",
+				"x-debug": { "value": "on", "optional": true },
+				"x-forbidden": { "absent": true },
+				"x-mode": { "value": "test", "not": true }
+			}
+		}
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	tests := []struct {
+		header   http.Header
+		expected bool
+	}{
+		{http.Header{"Authorization": {"Bearer abc"}}, true},
+		{http.Header{}, false},
+		{http.Header{"Authorization": {"Basic abc"}}, false},
+		{http.Header{"Authorization": {"Bearer abc"}, "X-Debug": {"on"}}, true},
+		{http.Header{"Authorization": {"Bearer abc"}, "X-Debug": {"off"}}, false},
+		{http.Header{"Authorization": {"Bearer abc"}, "X-Forbidden": {""}}, false},
+		{http.Header{"Authorization": {"Bearer abc"}, "X-Mode": {"live"}}, true},
+		{http.Header{"Authorization": {"Bearer abc"}, "X-Mode": {"test"}}, false},
+	}
+
+	for _, test := range tests {
+		matched, vars := result.CompareHeaders(test.header)
+		if matched != test.expected {
+			t.Errorf("expected %v for %v, got %v", test.expected, test.header,
+				matched)
+		}
+		if matched && vars["token"] != "abc" {
+			t.Errorf("expected \"token\" to be \"abc\", was %q", vars["token"])
+		}
+	}
+}
+
+// should match header names written in canonical case
+func TestCompareHeadersCanonicalCase(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get",
+			"headers": { "Authorization": "Bearer .*", "X-Request-ID": ".+" }
+		}
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	header := http.Header{"Authorization": {"Bearer abc"},
+		"X-Request-Id": {"1"}}
+	if matched, _ := result.CompareHeaders(header); !matched {
+		t.Error("expected canonical-case header names to match")
+	}
+}
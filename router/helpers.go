@@ -0,0 +1,163 @@
+package router
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// random is the source of every random value templates produce.  It's seeded
+// from the clock unless SeedRandom is called.
+var random = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// SeedRandom reseeds the values templates generate, so that the same requests
+// in the same order get the same responses.
+func SeedRandom(seed int64) {
+	random.Lock()
+	defer random.Unlock()
+	random.Rand = rand.New(rand.NewSource(seed))
+}
+
+// uuid generates a random (version 4) UUID.
+func uuid() string {
+	var b [16]byte
+	random.Lock()
+	random.Read(b[:])
+	random.Unlock()
+
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[:4], b[4:6], b[6:8], b[8:10],
+		b[10:])
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// offset moves t by a duration such as "-90m", or a number of days such as
+// "7d".
+func offset(by string, t time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(by, "d"); ok {
+		n, err := strconv.Atoi(days)
+		return t.AddDate(0, 0, n), err
+	}
+
+	d, err := time.ParseDuration(by)
+	return t.Add(d), err
+}
+
+// format renders t in a Go layout, or one of "rfc3339", "rfc1123", "unix" and
+// "unixMilli".
+func format(layout string, t time.Time) string {
+	switch layout {
+	case "rfc3339":
+		return t.Format(time.RFC3339)
+	case "rfc1123":
+		return t.Format(time.RFC1123)
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixMilli":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	default:
+		return t.Format(layout)
+	}
+}
+
+// randomInt gives a random integer from min to max, inclusive.
+func randomInt(min int, max int) (int, error) {
+	if max < min {
+		return 0, errors.New("randomInt needs a max no less than its min")
+	}
+
+	random.Lock()
+	defer random.Unlock()
+	return min + random.Intn(max-min+1), nil
+}
+
+const alphanumeric = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz" +
+	"0123456789"
+
+// randomString gives n random letters and digits, or characters from the
+// given set.
+func randomString(n int, charset ...string) string {
+	chars := []rune(alphanumeric)
+	if len(charset) > 0 && charset[0] != "" {
+		chars = []rune(strings.Join(charset, ""))
+	}
+
+	random.Lock()
+	defer random.Unlock()
+
+	s := make([]rune, max(n, 0))
+	for i := range s {
+		s[i] = chars[random.Intn(len(chars))]
+	}
+	return string(s)
+}
+
+// randomChoice gives one of its arguments at random.
+func randomChoice(choices ...any) (any, error) {
+	if len(choices) == 0 {
+		return nil, errors.New("randomChoice needs something to choose")
+	}
+
+	random.Lock()
+	defer random.Unlock()
+	return choices[random.Intn(len(choices))], nil
+}
+
+func base64Encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func base64Decode(s string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	return string(b), err
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha1Hex(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// helperFuncs generate and encode values in templates.
+var helperFuncs = map[string]any{
+	"uuid":         uuid,
+	"now":          now,
+	"offset":       offset,
+	"format":       format,
+	"randomInt":    randomInt,
+	"randomString": randomString,
+	"randomChoice": randomChoice,
+	"base64":       base64Encode,
+	"base64Decode": base64Decode,
+	"md5":          md5Hex,
+	"sha1":         sha1Hex,
+	"sha256":       sha256Hex,
+	"urlencode":    url.QueryEscape,
+	"urldecode":    url.QueryUnescape,
+}
@@ -0,0 +1,81 @@
+package router
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func execute(t *testing.T, text string) string {
+	tmpl, err := compileTemplate("test", text)
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	result, err := render(tmpl, &TemplateData{})
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+	return string(result)
+}
+
+// should generate the same random values from the same seed
+func TestSeedRandom(t *testing.T) {
+	text := `{{uuid}} {{randomInt 1 6}} {{randomString 8}} ` +
+		`{{randomChoice "a" "b" "c"}}`
+
+	SeedRandom(7)
+	first := execute(t, text)
+	SeedRandom(7)
+	second := execute(t, text)
+
+	if first != second {
+		t.Errorf("expected %q to equal %q", first, second)
+	}
+
+	pattern := `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-` +
+		`[0-9a-f]{12} [1-6] [[:alnum:]]{8} [abc]$`
+	if !regexp.MustCompile(pattern).MatchString(first) {
+		t.Errorf("unexpected random values %q", first)
+	}
+}
+
+// should format the current time and offsets from it
+func TestHelperTimes(t *testing.T) {
+	before := time.Now().Add(-48 * time.Hour).Unix()
+	result := execute(t, `{{now | offset "-2d" | format "unix"}}`)
+	after := time.Now().Add(-48 * time.Hour).Unix()
+
+	if n, _ := strconv.ParseInt(result, 10, 64); n < before || n > after {
+		t.Errorf("expected a time between %d and %d, received %d", before,
+			after, n)
+	}
+
+	date := execute(t, `{{now | offset "24h" | format "2006-01-02"}}`)
+	if expected := time.Now().UTC().Add(24 * time.Hour).Format(
+		"2006-01-02"); date != expected {
+		t.Errorf("expected %q, received %q", expected, date)
+	}
+}
+
+// should encode and hash values
+func TestHelperEncodings(t *testing.T) {
+	tests := map[string]string{
+		`{{base64 "hi there"}}`:               "aGkgdGhlcmU=",
+		`{{base64Decode "aGkgdGhlcmU="}}`:     "hi there",
+		`{{md5 "abc"}}`:                       "900150983cd24fb0d6963f7d28e17f72",
+		`{{sha1 "abc"}}`:                      "a9993e364706816aba3e25717850c26c9cd0d89d",
+		`{{urlencode "a b&c"}}`:               "a+b%26c",
+		`{{"abc" | sha256 | len}}`:            "64",
+		`{{randomString 4 "x"}}`:              "xxxx",
+		`{{now | format "rfc3339" | len}}`:    "20",
+		`{{urldecode "a+b%26c" | urlencode}}`: "a+b%26c",
+	}
+
+	for text, expected := range tests {
+		if result := execute(t, text); result != expected {
+			t.Errorf("expected %q for %s, received %q", expected, text, result)
+		}
+	}
+}
@@ -3,42 +3,95 @@ package router
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"text/template"
 )
 
-type Header struct {
-	Label *regexp.Regexp
-	Value *regexp.Regexp
-}
-
 type HTTPAction struct {
 	Request struct {
-		Path    []*regexp.Regexp
+		Path []*regexp.Regexp
+		// the variable a catch-all captures the rest of the path into, if the
+		// route ends in one.
+		Rest string
+		// the host a request must be sent to, if any.
+		Host    *regexp.Regexp
+		Query   []Query
 		Headers []Header
 		Body    *regexp.Regexp
+		JSON    *JSONMatcher
+		XML     *XMLMatcher
+		Form    *Form
+		GraphQL *GraphQLMatcher
+		// the SOAP action a request must name, if any.
+		SOAPAction *regexp.Regexp
 	}
 	Response struct {
 		Status  int
 		Headers map[string]string
 		Body    []byte
+		// the file to stream the body from, if the script names one.
+		File string
+		Faults
+		// the templates Headers and Body are rendered from.  A JSON body is
+		// kept as a document with templates in place of its strings.
+		headers  map[string]*template.Template
+		body     *template.Template
+		document any
 	}
+	Passthrough *Passthrough
+	After       []Webhook
+	Priority    int
+	// the scenario the script takes part in, if any.
+	Scenario *Scenario
 }
 
 type httpJSON struct {
 	Request struct {
-		Method  string            `json:"method"`
-		Path    string            `json:"path"`
-		Headers map[string]string `json:"headers"`
-		Body    any               `json:"body"`
+		Method     string                  `json:"method"`
+		Path       string                  `json:"path,omitempty"`
+		URL        string                  `json:"url,omitempty"`
+		Host       string                  `json:"host,omitempty"`
+		Partial    bool                    `json:"partialPath,omitempty"`
+		Query      map[string]any          `json:"query,omitempty"`
+		Headers    map[string]any          `json:"headers,omitempty"`
+		Body       any                     `json:"body,omitempty"`
+		JSON       any                     `json:"json,omitempty"`
+		JSONPath   []jsonPredicateJSON     `json:"jsonPath,omitempty"`
+		XPath      []xpathPredicateJSON    `json:"xpath,omitempty"`
+		Namespaces map[string]string       `json:"namespaces,omitempty"`
+		SOAPAction *string                 `json:"soapAction,omitempty"`
+		Form       map[string]any          `json:"form,omitempty"`
+		Files      map[string]formFileJSON `json:"files,omitempty"`
 	} `json:"request"`
 	Response struct {
-		Status  int               `json:"status"`
-		Headers map[string]string `json:"headers"`
-		Body    any               `json:"body"`
+		Status     int               `json:"status"`
+		Headers    map[string]string `json:"headers,omitempty"`
+		Body       any               `json:"body,omitempty"`
+		BodyText   *string           `json:"bodyText,omitempty"`
+		BodyBase64 string            `json:"bodyBase64,omitempty"`
+		BodyFile   string            `json:"bodyFile,omitempty"`
+		Delay      string            `json:"delay,omitempty"`
+		DelayMax   string            `json:"delayMax,omitempty"`
+		Hang       bool              `json:"hang,omitempty"`
+		Drop       string            `json:"drop,omitempty"`
+		Data       any               `json:"data,omitempty"`
+		Errors     any               `json:"errors,omitempty"`
 	} `json:"response"`
+	GraphQL     *graphqlJSON `json:"graphql,omitempty"`
+	Passthrough *struct {
+		Upstream string `json:"upstream"`
+	} `json:"passthrough,omitempty"`
+	After    []webhookJSON `json:"after,omitempty"`
+	Priority int           `json:"priority,omitempty"`
+	Scenario *scenarioJSON `json:"scenario,omitempty"`
 }
 
 func requestPath(action *HTTPAction, parsed *httpJSON) error {
@@ -46,17 +99,47 @@ func requestPath(action *HTTPAction, parsed *httpJSON) error {
 
 	switch method {
 	case "delete", "get", "head", "options", "patch", "post", "put":
-		re := regexp.MustCompile(method)
+		re := regexp.MustCompile("^" + method + "$")
 		action.Request.Path = append(action.Request.Path, re)
 	default:
 		return errors.New("unrecognized method")
 	}
 
-	for _, s := range strings.Split(parsed.Request.Path, "/") {
-		if s == "" {
+	if parsed.Request.URL != "" {
+		if parsed.Request.Path != "" {
+			return errors.New("request has both a path and a url")
+		}
+		return requestURL(action, parsed.Request.URL)
+	}
+
+	segments := strings.Split(parsed.Request.Path, "/")
+	return pathSegments(action, segments, func(s string) (*regexp.Regexp,
+		error) {
+		return compile(s, parsed.Request.Partial)
+	})
+}
+
+// catchAll finds the segments that consume the rest of a path: a bare "**",
+// or "{{**name}}" to capture the remainder as name.
+var catchAll = regexp.MustCompile(`^(?:\*\*|{{\*\*(\w+)}})$`)
+
+// pathSegments compiles each segment of a path into the action's route.  The
+// empty segments before a leading slash and after a trailing one are dropped,
+// as they are from requests.
+func pathSegments(action *HTTPAction, segments []string,
+	compile func(string) (*regexp.Regexp, error)) error {
+	for i, s := range segments {
+		if s == "" && (i == 0 || i == len(segments)-1) {
 			continue
-		} else if re, err := regexp.Compile(s); err != nil {
+		} else if m := catchAll.FindStringSubmatch(s); m != nil {
+			if strings.Join(segments[i+1:], "") != "" {
+				return errors.New("catch-all must be the last path segment")
+			} else if action.Request.Rest = m[1]; m[1] == "" {
+				action.Request.Rest = "rest"
+			}
 			return nil
+		} else if re, err := compile(s); err != nil {
+			return err
 		} else {
 			action.Request.Path = append(action.Request.Path, re)
 		}
@@ -65,30 +148,72 @@ func requestPath(action *HTTPAction, parsed *httpJSON) error {
 	return nil
 }
 
-func requestHeaders(action *HTTPAction, parsed *httpJSON) error {
-	var headers []Header
-	var err error
-	var entry Header
+// requestURL parses the documented url syntax, in which literal text may hold
+// {{/pattern/flags}} regular expressions, along with its query string.
+func requestURL(action *HTTPAction, u string) error {
+	parts := splitPattern(u, "?")
+	if len(parts) > 2 {
+		return errors.New("url has more than one query string")
+	}
 
-	for label, value := range parsed.Request.Headers {
-		if entry.Label, err = regexp.Compile(label); err != nil {
-			return err
+	// request paths are matched decoded, as queries are.
+	segments := splitPattern(parts[0], "/")
+	err := pathSegments(action, segments, func(s string) (*regexp.Regexp,
+		error) {
+		return compilePattern(s, url.PathUnescape, false)
+	})
+	if err != nil || len(parts) == 1 {
+		return err
+	}
+
+	for _, s := range splitPattern(parts[1], "&") {
+		if s == "" {
+			continue
 		}
-		if entry.Value, err = regexp.Compile(value); err != nil {
+
+		kv := append(splitPattern(s, "="), "")
+		if key, err := url.QueryUnescape(kv[0]); err != nil {
+			return err
+		} else if re, err := compilePattern(kv[1], url.QueryUnescape, false); err != nil {
 			return err
+		} else {
+			query := Query{Key: key, Value: re}
+			action.Request.Query = append(action.Request.Query, query)
 		}
-		headers = append(headers, entry)
 	}
-	action.Request.Headers = headers
 
 	return nil
 }
 
 func requestBody(action *HTTPAction, parsed *httpJSON) error {
 	var err error
+	if parsed.Request.Body == nil {
+		action.Request.Body, err = regexp.Compile("")
+		return err
+	}
+
 	body, _ := json.Marshal(parsed.Request.Body)
-	unquoted, _ := strconv.Unquote(string(body))
-	action.Request.Body, err = regexp.Compile(unquoted)
+	if unquoted, err := strconv.Unquote(string(body)); err == nil {
+		body = []byte(unquoted)
+	}
+	action.Request.Body, err = regexp.Compile(string(body))
+	return err
+}
+
+func requestHost(action *HTTPAction, parsed *httpJSON) error {
+	var err error
+	if parsed.Request.Host != "" {
+		action.Request.Host, err = compilePattern(
+			strings.ToLower(parsed.Request.Host), nil, false)
+	}
+	return err
+}
+
+func passthrough(action *HTTPAction, parsed *httpJSON) error {
+	var err error
+	if parsed.Passthrough != nil {
+		action.Passthrough, err = NewPassthrough(parsed.Passthrough.Upstream)
+	}
 	return err
 }
 
@@ -102,7 +227,10 @@ func HTTPActionFromJSON(input []byte) (*HTTPAction, error) {
 	}
 
 	parsers := []func(action *HTTPAction, parsed *httpJSON) error{
-		unmarshal, requestPath, requestHeaders, requestBody,
+		unmarshal, graphqlDefaults, requestPath, requestHost, requestQuery,
+		requestHeaders, requestBody, requestJSON, requestXML, requestForm,
+		requestGraphQL, passthrough, after, responseFaults, graphqlResponse,
+		responseBody, scenario,
 	}
 	for _, f := range parsers {
 		if err := f(action, &parsed); err != nil {
@@ -110,24 +238,38 @@ func HTTPActionFromJSON(input []byte) (*HTTPAction, error) {
 		}
 	}
 
-	body, _ := json.Marshal(parsed.Response.Body)
-	action.Response.Body = body
+	action.Priority = parsed.Priority
+
 	action.Response.Status = parsed.Response.Status
 	action.Response.Headers = parsed.Response.Headers
 
+	if err := responseTemplates(action); err != nil {
+		return nil, err
+	}
 	return action, nil
 }
 
-func replace(original []byte, vars map[string]string) []byte {
-	re := regexp.MustCompile(`{{([[:alnum:]]+)}}`)
-	matches := re.FindAllSubmatch(original, -1)
-	for _, m := range matches {
-		id := string(m[1])
-		r := regexp.MustCompile(string(m[0]))
-		original = r.ReplaceAll(original, []byte(vars[id]))
+// responseTemplates parses the response's headers as templates, once and for
+// all.
+func responseTemplates(action *HTTPAction) error {
+	var err error
+	r := &action.Response
+
+	r.headers = make(map[string]*template.Template)
+	for k, v := range r.Headers {
+		if r.headers[k], err = compileTemplate(k, v); err != nil {
+			return fmt.Errorf("header %q: %w", k, err)
+		}
 	}
+	return nil
+}
 
-	return original
+// replace substitutes captures for the "{{name}}" and "{{$name}}" variables
+// in TCP scripts.
+func replace(original []byte, vars map[string]string) []byte {
+	return legacyVariable.ReplaceAllFunc(original, func(v []byte) []byte {
+		return []byte(vars[string(legacyVariable.FindSubmatch(v)[2])])
+	})
 }
 
 func merge(a map[string]string, b map[string]string) map[string]string {
@@ -137,29 +279,183 @@ func merge(a map[string]string, b map[string]string) map[string]string {
 	return a
 }
 
-// TODO: where are my tests?
-func (a *HTTPAction) CompareHeaders(label string, value string) (bool, map[string]string) {
-	for _, h := range a.Request.Headers {
-		if matched, ls := match(h.Label, label); !matched {
-			continue
-		} else if matched, vs := match(h.Value, value); !matched {
-			continue
+// CompareBody matches the request body against the script's regular
+// expression and, if it has them, its JSON, XML and GraphQL matchers.
+func (a *HTTPAction) CompareBody(body string) (bool, map[string]string) {
+	matched, vars := match(a.Request.Body, body)
+	if !matched {
+		return false, nil
+	}
+
+	if a.Request.JSON != nil {
+		if matched, groups := a.Request.JSON.Match([]byte(body)); !matched {
+			return false, nil
 		} else {
-			return true, merge(ls, vs)
+			merge(vars, groups)
 		}
 	}
 
-	return false, nil
+	if a.Request.XML != nil {
+		if matched, groups := a.Request.XML.Match([]byte(body)); !matched {
+			return false, nil
+		} else {
+			merge(vars, groups)
+		}
+	}
+
+	if a.Request.GraphQL != nil {
+		if matched, groups := a.Request.GraphQL.Match([]byte(body)); !matched {
+			return false, nil
+		} else {
+			merge(vars, groups)
+		}
+	}
+
+	return true, vars
 }
 
-func (a *HTTPAction) CompareBody(body string) (bool, map[string]string) {
-	return match(a.Request.Body, body)
+// Specificity counts the constraints an action places on a request beyond its
+// route, so that narrower scripts can be tried before broader ones.
+func (a *HTTPAction) Specificity() int {
+	n := len(a.Request.Query) + len(a.Request.Headers)
+	if a.Request.Host != nil {
+		n++
+	}
+	if a.Request.Body != nil && a.Request.Body.String() != "" {
+		n++
+	}
+	if a.Request.JSON != nil {
+		n += len(a.Request.JSON.Predicates)
+		if a.Request.JSON.Document != nil {
+			n++
+		}
+	}
+	if a.Request.XML != nil {
+		n += len(a.Request.XML.Predicates)
+	}
+	if a.Request.SOAPAction != nil {
+		n++
+	}
+	if a.Request.Form != nil {
+		n += len(a.Request.Form.Fields) + len(a.Request.Form.Files)
+	}
+	if g := a.Request.GraphQL; g != nil {
+		n += len(g.Fields) + 1
+		if g.Variables != nil {
+			n += len(g.Variables.Predicates) + 1
+		}
+	}
+	if a.Scenario != nil && a.Scenario.State != "" {
+		n++
+	}
+	return n
+}
+
+// CompareHost matches the host a request was sent to, without its port,
+// against the script's, if it has one.
+func (a *HTTPAction) CompareHost(req *http.Request) (bool, map[string]string) {
+	if a.Request.Host == nil {
+		return true, nil
+	}
+
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return match(a.Request.Host, strings.ToLower(host))
 }
 
-func (a *HTTPAction) Write(w http.ResponseWriter, vars map[string]string) {
-	for k, v := range a.Response.Headers {
-		w.Header().Set(k, string(replace([]byte(v), vars)))
+// Match compares everything but the route of a request against the script,
+// returning the captures made along the way.
+func (a *HTTPAction) Match(req *http.Request, body []byte) (bool,
+	map[string]string) {
+	vars := make(map[string]string)
+
+	if matched, groups := a.CompareHost(req); !matched {
+		return false, nil
+	} else {
+		merge(vars, groups)
 	}
+
+	if matched, groups := a.CompareQuery(req.URL.Query()); !matched {
+		return false, nil
+	} else {
+		merge(vars, groups)
+	}
+
+	if matched, groups := a.CompareHeaders(req.Header); !matched {
+		return false, nil
+	} else {
+		merge(vars, groups)
+	}
+
+	if matched, groups := a.CompareSOAPAction(req); !matched {
+		return false, nil
+	} else {
+		merge(vars, groups)
+	}
+
+	if matched, groups := a.CompareForm(req, body); !matched {
+		return false, nil
+	} else {
+		merge(vars, groups)
+	}
+
+	if matched, groups := a.CompareBody(string(body)); !matched {
+		return false, nil
+	} else {
+		merge(vars, groups)
+	}
+
+	return true, vars
+}
+
+func (a *HTTPAction) Write(w http.ResponseWriter, req *http.Request,
+	vars map[string]string) {
+	if !a.Response.wait(req) {
+		return
+	} else if a.Response.Drop == DropBeforeHeaders {
+		drop(w, false)
+		return
+	}
+
+	// headers are rendered in order, so that seeded random values repeat.
+	data := templateData(req, vars)
+	headers := make(map[string]string)
+	for _, k := range sortedKeys(a.Response.headers) {
+		if v, err := render(a.Response.headers[k], data); err != nil {
+			renderError(w, err)
+			return
+		} else {
+			headers[k] = string(v)
+		}
+	}
+
+	for k, v := range headers {
+		w.Header().Set(k, v)
+	}
+
+	body, length, err := a.openBody(w, data)
+	if err != nil {
+		renderError(w, err)
+		return
+	}
+	defer body.Close()
+
+	if a.Response.Drop == DropMidBody {
+		w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+		w.WriteHeader(a.Response.Status)
+		io.CopyN(w, body, length/2)
+		drop(w, true)
+		return
+	}
+
 	w.WriteHeader(a.Response.Status)
-	w.Write(replace(a.Response.Body, vars))
+	io.Copy(w, body)
+}
+
+// renderError answers with a 500 when a response can't be produced.
+func renderError(w http.ResponseWriter, err error) {
+	log.Printf("mocket: error rendering response (%v)\n", err)
+	w.WriteHeader(500)
 }
@@ -1,6 +1,30 @@
 package router
 
-import "testing"
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// parseAction parses a script, failing the test if it doesn't parse.
+func parseAction(t *testing.T, script string) *HTTPAction {
+	result, err := HTTPActionFromJSON([]byte(script))
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+	return result
+}
+
+// requestAction parses a script for requests with the given method and the
+// rest of the request's keys.
+func requestAction(t *testing.T, method string, request string) *HTTPAction {
+	return parseAction(t, `{
+		"request": {
+			"method": "`+method+`",
+			`+request+`
+		}
+	}`)
+}
 
 // should correctly parse the request method
 func TestHTTPActionRequestMethod(t *testing.T) {
@@ -20,8 +44,8 @@ func TestHTTPActionRequestMethod(t *testing.T) {
 	}
 
 	method := result.Request.Path[0].String()
-	if method != "get" {
-		t.Errorf("expected \"get\", received %q", method)
+	if method != "^get$" {
+		t.Errorf("expected \"^get$\", received %q", method)
 	}
 }
 
@@ -43,8 +67,8 @@ func TestHTTPActionRequestMethodCase(t *testing.T) {
 	}
 
 	method := result.Request.Path[0].String()
-	if method != "get" {
-		t.Errorf("expected \"get\", received %q", method)
+	if method != "^get$" {
+		t.Errorf("expected \"^get$\", received %q", method)
 	}
 }
 
@@ -78,7 +102,7 @@ func TestHTTPActionRequestPath(t *testing.T) {
 		t.Fatal("path is nil")
 	}
 
-	expected := []string{"get", "my", "test", "path"}
+	expected := []string{"^get$", "^(?:my)$", "^(?:test)$", "^(?:path)$"}
 	if len(result.Request.Path) != len(expected) {
 		t.Fatal("path is wrong length")
 	}
@@ -90,6 +114,110 @@ func TestHTTPActionRequestPath(t *testing.T) {
 	}
 }
 
+// should correctly parse a url with embedded regular expressions
+func TestHTTPActionRequestURL(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get",
+			"url": "/users/{{/(?<user_id>\\d+)/}}/a.json"
+		}
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	expected := []string{"^get$", "^users$", `^(?:(?P<user_id>\d+))$`,
+		`^a\.json$`}
+	if len(result.Request.Path) != len(expected) {
+		t.Fatalf("expected a path length of %d, got %d", len(expected),
+			len(result.Request.Path))
+	}
+
+	for i, s := range expected {
+		if result.Request.Path[i].String() != s {
+			t.Errorf("expected %q, received %q", s, result.Request.Path[i])
+		}
+	}
+}
+
+// should percent-decode the literal text of a url's path
+func TestHTTPActionRequestURLEscapes(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": { "method": "get", "url": "/files/my%20doc/{{/v\\d+/}}%25" }
+	}`))
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	expected := []string{"^get$", "^files$", `^my doc$`, `^(?:v\d+)%$`}
+	if len(result.Request.Path) != len(expected) {
+		t.Fatalf("expected a path length of %d, got %d", len(expected),
+			len(result.Request.Path))
+	}
+	for i, s := range expected {
+		if result.Request.Path[i].String() != s {
+			t.Errorf("expected %q, received %q", s, result.Request.Path[i])
+		}
+	}
+
+	_, err = HTTPActionFromJSON([]byte(`{
+		"request": { "method": "get", "url": "/files/100%" }
+	}`))
+	if err == nil {
+		t.Error("expected an error for a bad escape")
+	}
+}
+
+// should return an error when given both a path and a url
+func TestHTTPActionRequestURLAndPath(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get",
+			"path": "/a",
+			"url": "/a"
+		}
+	}`))
+
+	if err == nil {
+		t.Error("error should not be nil")
+	}
+
+	if result != nil {
+		t.Error("expected result to be nil")
+	}
+}
+
+// should match the query string from a url
+func TestHTTPActionCompareQuery(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get",
+			"url": "/path?id={{/(\\d+)/}}&type=a%20b"
+		}
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	matched, vars := result.CompareQuery(url.Values{
+		"id":    {"x", "1234"},
+		"type":  {"a b"},
+		"extra": {"ignored"},
+	})
+	if !matched {
+		t.Fatal("expected query to match")
+	}
+	if vars["1"] != "1234" {
+		t.Errorf("expected \"1\" to be \"1234\", was %q", vars["1"])
+	}
+
+	if matched, _ := result.CompareQuery(url.Values{"id": {"1234"}}); matched {
+		t.Error("expected a missing parameter not to match")
+	}
+}
+
 // should correctly parse request headers
 func TestHTTPActionRequestHeaders(t *testing.T) {
 	result, err := HTTPActionFromJSON([]byte(`{
@@ -110,11 +238,11 @@ func TestHTTPActionRequestHeaders(t *testing.T) {
 		t.Errorf("expected 1 header, got %d", headerLen)
 	}
 
-	if result.Request.Headers[0].Label.String() != "content-type" {
+	if result.Request.Headers[0].Label.String() != "^(?:(?i)content-type)$" {
 		t.Error("did not correctly parse header label")
 	}
 
-	if result.Request.Headers[0].Value.String() != "test" {
+	if result.Request.Headers[0].Value.String() != "^(?:test)$" {
 		t.Error("did not correctly parse header value")
 	}
 }
@@ -275,3 +403,104 @@ func TestHTTPActionReplaceNil(t *testing.T) {
 		t.Errorf("expected \"test \", got %q", result)
 	}
 }
+
+// should match a request's query and body
+func TestHTTPActionMatch(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "post",
+			"url": "/path?id={{/(?<id>\\d+)/}}",
+			"body": "(?P<name>[a-z]+)"
+		}
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	req := httptest.NewRequest("POST", "/path?id=12", nil)
+	matched, vars := result.Match(req, []byte("name"))
+	if !matched {
+		t.Fatal("expected request to match")
+	}
+	if vars["id"] != "12" || vars["name"] != "name" {
+		t.Errorf("unexpected captures %v", vars)
+	}
+
+	req = httptest.NewRequest("POST", "/path?id=12", nil)
+	if matched, _ := result.Match(req, []byte("123")); matched {
+		t.Error("expected a mismatched body not to match")
+	}
+}
+
+// should parse a catch-all at the end of a url
+func TestHTTPActionRequestCatchAll(t *testing.T) {
+	for script, expected := range map[string]string{
+		`"url": "/files/**"`:          "rest",
+		`"url": "/files/{{**key}}"`:   "key",
+		`"path": "/files/{{**key}}/"`: "key",
+	} {
+		result, err := HTTPActionFromJSON([]byte(`{
+			"request": { "method": "get", ` + script + ` }
+		}`))
+		if err != nil {
+			t.Fatalf("received error (%v)", err)
+		}
+
+		if len(result.Request.Path) != 2 {
+			t.Errorf("expected a path length of 2, got %d",
+				len(result.Request.Path))
+		}
+		if result.Request.Rest != expected {
+			t.Errorf("expected %q, received %q", expected, result.Request.Rest)
+		}
+	}
+}
+
+// should return an error for a catch-all before the end of a url
+func TestHTTPActionRequestCatchAllError(t *testing.T) {
+	_, err := HTTPActionFromJSON([]byte(`{
+		"request": { "method": "get", "url": "/files/**/meta" }
+	}`))
+
+	if err == nil {
+		t.Error("error should not be nil")
+	}
+}
+
+// should match the host a request was sent to, ignoring its port
+func TestHTTPActionCompareHost(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get",
+			"host": "{{/(?<tenant>\\w+)/}}.example.com"
+		}
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	tests := []struct {
+		host     string
+		expected bool
+	}{
+		{"acme.example.com", true},
+		{"ACME.example.com:8080", true},
+		{"example.com", false},
+		{"acme.example.org", false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Host = test.host
+		matched, vars := result.CompareHost(req)
+		if matched != test.expected {
+			t.Errorf("expected %v for %q, got %v", test.expected, test.host,
+				matched)
+		}
+		if matched && vars["tenant"] != "acme" {
+			t.Errorf("expected \"tenant\" to be \"acme\", was %q", vars["tenant"])
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// JSONMatcher compares a request body structurally, after decoding it as
+// JSON.  Document is a partial document the body must contain, and every one
+// of Predicates must hold as well.
+type JSONMatcher struct {
+	Document   any
+	Predicates []JSONPredicate
+}
+
+// JSONPredicate requires that Path selects a value matching Value, or just
+// that it selects something when Value is nil.  The first matching value is
+// captured as As, if it's set.
+type JSONPredicate struct {
+	Path  JSONPath
+	Value any
+	As    string
+}
+
+type jsonPredicateJSON struct {
+	Path    string          `json:"path"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Regex   *string         `json:"regex,omitempty"`
+	Partial bool            `json:"partial,omitempty"`
+	As      string          `json:"as,omitempty"`
+}
+
+// compileJSON prepares an expected document for matching: its strings are
+// compiled in the url syntax, so they match literally unless they hold
+// {{/pattern/flags}} regular expressions.
+func compileJSON(doc any) (any, error) {
+	switch v := doc.(type) {
+	case string:
+		return compilePattern(v, nil, false)
+	case map[string]any:
+		compiled := make(map[string]any)
+		for k, child := range v {
+			var err error
+			if compiled[k], err = compileJSON(child); err != nil {
+				return nil, err
+			}
+		}
+		return compiled, nil
+	case []any:
+		compiled := make([]any, len(v))
+		for i, child := range v {
+			var err error
+			if compiled[i], err = compileJSON(child); err != nil {
+				return nil, err
+			}
+		}
+		return compiled, nil
+	default:
+		return v, nil
+	}
+}
+
+func jsonPredicate(spec *jsonPredicateJSON) (JSONPredicate, error) {
+	var err error
+	p := JSONPredicate{As: spec.As}
+
+	if p.Path, err = ParseJSONPath(spec.Path); err != nil {
+		return p, err
+	}
+
+	switch {
+	case spec.Value != nil && spec.Regex != nil:
+		err = fmt.Errorf("json path %q has both a value and a regex", spec.Path)
+	case spec.Regex != nil:
+		p.Value, err = compile(*spec.Regex, spec.Partial)
+	case spec.Value != nil:
+		var value any
+		if err = json.Unmarshal(spec.Value, &value); err == nil {
+			p.Value, err = compileJSON(value)
+		}
+		if value == nil {
+			// distinguish an expected null from no expectation at all.
+			p.Value = jsonNull{}
+		}
+	}
+
+	return p, err
+}
+
+type jsonNull struct{}
+
+func requestJSON(action *HTTPAction, parsed *httpJSON) error {
+	if parsed.Request.JSON == nil && len(parsed.Request.JSONPath) == 0 {
+		return nil
+	}
+
+	var err error
+	m := new(JSONMatcher)
+	if parsed.Request.JSON != nil {
+		if m.Document, err = compileJSON(parsed.Request.JSON); err != nil {
+			return err
+		}
+	}
+
+	for i := range parsed.Request.JSONPath {
+		if p, err := jsonPredicate(&parsed.Request.JSONPath[i]); err != nil {
+			return err
+		} else {
+			m.Predicates = append(m.Predicates, p)
+		}
+	}
+
+	action.Request.JSON = m
+	return nil
+}
+
+// matchJSON compares a decoded value against a compiled expectation.  Objects
+// need only contain the keys expected of them, but arrays must match element
+// for element.  Captures are added to vars.
+func matchJSON(expected any, actual any, vars map[string]string) bool {
+	switch e := expected.(type) {
+	case *regexp.Regexp:
+		s, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		matched, groups := match(e, s)
+		merge(vars, groups)
+		return matched
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, v := range e {
+			if child, ok := a[k]; !ok || !matchJSON(v, child, vars) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		a, ok := actual.([]any)
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for i := range e {
+			if !matchJSON(e[i], a[i], vars) {
+				return false
+			}
+		}
+		return true
+	case jsonNull:
+		return actual == nil
+	default:
+		return expected == actual
+	}
+}
+
+// jsonString renders a matched value for use as a template variable: strings
+// as they are, and anything else as JSON.
+func jsonString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// Match decodes body and compares it against the matcher, returning the
+// captures made along the way.
+func (m *JSONMatcher) Match(body []byte) (bool, map[string]string) {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return false, nil
+	}
+
+	vars := make(map[string]string)
+	if m.Document != nil && !matchJSON(m.Document, doc, vars) {
+		return false, nil
+	}
+
+	for _, p := range m.Predicates {
+		matched := false
+		for _, v := range p.Path.Select(doc) {
+			groups := make(map[string]string)
+			if p.Value == nil || matchJSON(p.Value, v, groups) {
+				if matched = true; p.As != "" {
+					groups[p.As] = jsonString(v)
+				}
+				merge(vars, groups)
+				break
+			}
+		}
+
+		if !matched {
+			return false, nil
+		}
+	}
+
+	return true, vars
+}
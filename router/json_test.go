@@ -0,0 +1,90 @@
+package router
+
+import "testing"
+
+// should match a partial document regardless of key order and whitespace
+func TestCompareBodyJSONDocument(t *testing.T) {
+	result := requestAction(t, "post", `"json": {
+		"user": { "id": "{{/(?<id>\\d+)/}}", "admin": false },
+		"tags": ["a.b", null]
+	}`)
+
+	tests := []struct {
+		body     string
+		expected bool
+	}{
+		{`{"tags":["a.b",null],"user":{"admin":false,"id":"42","name":"x"}}`, true},
+		{`{ "user": { "id": "42", "admin": false }, "tags": [ "a.b", null ] }`, true},
+		{`{"user":{"id":"42","admin":true},"tags":["a.b",null]}`, false},
+		{`{"user":{"id":"x","admin":false},"tags":["a.b",null]}`, false},
+		{`{"user":{"id":"42","admin":false},"tags":["axb",null]}`, false},
+		{`{"user":{"id":"42","admin":false},"tags":["a.b"]}`, false},
+		{`{"user":{"id":"42"},"tags":["a.b",null]}`, false},
+		{`not json`, false},
+	}
+
+	for _, test := range tests {
+		matched, vars := result.CompareBody(test.body)
+		if matched != test.expected {
+			t.Errorf("expected %v for %s, got %v", test.expected, test.body,
+				matched)
+		}
+		if matched && vars["id"] != "42" {
+			t.Errorf("expected \"id\" to be \"42\", was %q", vars["id"])
+		}
+	}
+}
+
+// should match JSONPath predicates and capture the values they select
+func TestCompareBodyJSONPath(t *testing.T) {
+	result := requestAction(t, "post", `"jsonPath": [
+		{ "path": "$.items[*].sku", "regex": "B-\\d+", "as": "sku" },
+		{ "path": "$.total", "value": 12.5, "as": "total" },
+		{ "path": "$.customer", "as": "customer" },
+		{ "path": "$.note", "value": null }
+	]`)
+
+	matched, vars := result.CompareBody(`{
+		"items": [{ "sku": "A-1" }, { "sku": "B-2" }],
+		"total": 12.5,
+		"customer": { "id": 7 },
+		"note": null
+	}`)
+	if !matched {
+		t.Fatal("expected body to match")
+	}
+
+	expected := map[string]string{
+		"sku": "B-2", "total": "12.5", "customer": `{"id":7}`,
+	}
+	for k, v := range expected {
+		if vars[k] != v {
+			t.Errorf("expected %q to be %q, was %q", k, v, vars[k])
+		}
+	}
+
+	for _, body := range []string{
+		`{"items":[{"sku":"A-1"}],"total":12.5,"customer":{},"note":null}`,
+		`{"items":[{"sku":"B-2"}],"total":12,"customer":{},"note":null}`,
+		`{"items":[{"sku":"B-2"}],"total":12.5,"note":null}`,
+		`{"items":[{"sku":"B-2"}],"total":12.5,"customer":{},"note":"x"}`,
+	} {
+		if matched, _ := result.CompareBody(body); matched {
+			t.Errorf("expected %s not to match", body)
+		}
+	}
+}
+
+// should return an error for a predicate with both a value and a regex
+func TestHTTPActionRequestJSONPathError(t *testing.T) {
+	_, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "post",
+			"jsonPath": [{ "path": "$.a", "value": 1, "regex": "1" }]
+		}
+	}`))
+
+	if err == nil {
+		t.Error("error should not be nil")
+	}
+}
@@ -0,0 +1,152 @@
+package router
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath selects values from a decoded JSON document.  It supports the
+// common subset of JSONPath: the root "$", children by ".name" or ['name'],
+// array indices by [n] (negative indices count from the end), wildcards by
+// ".*" or [*], and recursive descent by "..name" or "..*".
+type JSONPath []jsonStep
+
+type jsonStep struct {
+	key       string
+	index     int
+	indexed   bool
+	wildcard  bool
+	recursive bool
+}
+
+func ParseJSONPath(path string) (JSONPath, error) {
+	var steps JSONPath
+	s := strings.TrimSpace(path)
+
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("json path %q must start with $", path)
+	}
+	s = s[1:]
+
+	for s != "" {
+		var step jsonStep
+
+		switch {
+		case strings.HasPrefix(s, ".."):
+			step.recursive = true
+			s = s[2:]
+			if strings.HasPrefix(s, "[") {
+				break
+			}
+			fallthrough
+		case strings.HasPrefix(s, "."):
+			s = strings.TrimPrefix(s, ".")
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			if step.key = s[:end]; step.key == "" {
+				return nil, fmt.Errorf("json path %q has an empty name", path)
+			}
+			step.wildcard = step.key == "*"
+			s = s[end:]
+			steps = append(steps, step)
+			continue
+		case !strings.HasPrefix(s, "["):
+			return nil, fmt.Errorf("json path %q is malformed at %q", path, s)
+		}
+
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("json path %q has an unclosed [", path)
+		}
+		inner := strings.TrimSpace(s[1:end])
+		s = s[end+1:]
+
+		if inner == "*" {
+			step.wildcard = true
+		} else if n, err := strconv.Atoi(inner); err == nil {
+			step.index, step.indexed = n, true
+		} else if key, err := strconv.Unquote(inner); err == nil {
+			step.key = key
+		} else if len(inner) >= 2 && inner[0] == '\'' &&
+			inner[len(inner)-1] == '\'' {
+			step.key = inner[1 : len(inner)-1]
+		} else {
+			return nil, fmt.Errorf("json path %q has a malformed [%s]", path,
+				inner)
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// children gives the values a step selects directly beneath v.
+func (step *jsonStep) children(v any) []any {
+	switch node := v.(type) {
+	case map[string]any:
+		if step.wildcard {
+			var values []any
+			for _, k := range sortedKeys(node) {
+				values = append(values, node[k])
+			}
+			return values
+		} else if child, ok := node[step.key]; ok && !step.indexed {
+			return []any{child}
+		}
+	case []any:
+		if step.wildcard {
+			return node
+		} else if step.indexed {
+			i := step.index
+			if i < 0 {
+				i += len(node)
+			}
+			if i >= 0 && i < len(node) {
+				return []any{node[i]}
+			}
+		}
+	}
+
+	return nil
+}
+
+// descend gives the values a recursive step selects anywhere beneath v.
+func (step *jsonStep) descend(v any) []any {
+	values := step.children(v)
+
+	switch node := v.(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(node) {
+			values = append(values, step.descend(node[k])...)
+		}
+	case []any:
+		for _, child := range node {
+			values = append(values, step.descend(child)...)
+		}
+	}
+
+	return values
+}
+
+// Select returns every value the path selects from doc, in document order.
+func (p JSONPath) Select(doc any) []any {
+	values := []any{doc}
+
+	for _, step := range p {
+		var next []any
+		for _, v := range values {
+			if step.recursive {
+				next = append(next, step.descend(v)...)
+			} else {
+				next = append(next, step.children(v)...)
+			}
+		}
+		values = next
+	}
+
+	return values
+}
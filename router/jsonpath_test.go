@@ -0,0 +1,51 @@
+package router
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// should select values by name, index, wildcard and recursive descent
+func TestJSONPathSelect(t *testing.T) {
+	var doc any
+	json.Unmarshal([]byte(`{
+		"user": { "name": "ann", "tags": ["a", "b", "c"] },
+		"items": [{ "id": 1 }, { "id": 2, "name": "two" }]
+	}`), &doc)
+
+	tests := []struct {
+		path     string
+		expected []any
+	}{
+		{"$.user.name", []any{"ann"}},
+		{"$['user'][\"name\"]", []any{"ann"}},
+		{"$.user.tags[1]", []any{"b"}},
+		{"$.user.tags[-1]", []any{"c"}},
+		{"$.user.tags[*]", []any{"a", "b", "c"}},
+		{"$.items.*.id", []any{1.0, 2.0}},
+		{"$..name", []any{"two", "ann"}},
+		{"$.missing", nil},
+	}
+
+	for _, test := range tests {
+		path, err := ParseJSONPath(test.path)
+		if err != nil {
+			t.Errorf("received error for %q (%v)", test.path, err)
+			continue
+		}
+		if values := path.Select(doc); !reflect.DeepEqual(values, test.expected) {
+			t.Errorf("expected %v for %q, received %v", test.expected, test.path,
+				values)
+		}
+	}
+}
+
+// should return an error for a malformed path
+func TestParseJSONPathError(t *testing.T) {
+	for _, path := range []string{"user", "$.", "$[1", "$[x]", "$x"} {
+		if _, err := ParseJSONPath(path); err == nil {
+			t.Errorf("expected an error for %q", path)
+		}
+	}
+}
@@ -2,36 +2,68 @@ package router
 
 import (
 	"regexp"
+	"sort"
+	"strings"
 )
 
 type Path struct {
 	Regexp *regexp.Regexp
 	// this should be a generic in the future.
-	Action *HTTPAction
+	Actions []*HTTPAction
 
 	Children []*Path
+
+	// catch-all nodes match whatever is left of a path, however many
+	// segments that is, and capture it as Name.
+	Name string
+	Rest []*Path
 }
 
-func (p *Path) findChild(name string) (*Path, map[string]string) {
-	for _, child := range p.Children {
-		re := child.Regexp
-		if re.String() == name {
-			return child, nil
+// AddCatchAll adds a node beneath p that matches the rest of any path,
+// capturing it as name.
+func (p *Path) AddCatchAll(name string) *Path {
+	for _, rest := range p.Rest {
+		if rest.Name == name {
+			return rest
 		}
-		if matched, groups := match(re, name); matched {
-			return child, groups
+	}
+
+	rest := &Path{Name: name}
+	p.Rest = append(p.Rest, rest)
+	return rest
+}
+
+// AddAction adds a candidate action to the node, keeping them in the order
+// they should be tried: highest priority first, then most specific.  Actions
+// that tie keep the order they were added in.
+func (p *Path) AddAction(action *HTTPAction) {
+	p.Actions = append(p.Actions, action)
+	sort.SliceStable(p.Actions, func(i, j int) bool {
+		a, b := p.Actions[i], p.Actions[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.Specificity() > b.Specificity()
+	})
+}
+
+// childNamed finds the child whose regular expression is exactly name, without
+// trying to match name against it.
+func (p *Path) childNamed(name string) *Path {
+	for _, child := range p.Children {
+		if child.Regexp.String() == name {
+			return child
 		}
 	}
 
-	return nil, nil
+	return nil
 }
 
 func (p *Path) addChild(child *Path) {
 	if child == nil {
 		return
 	}
-	name := child.Regexp.String()
-	if node, _ := p.findChild(name); node == nil {
+	if node := p.childNamed(child.Regexp.String()); node == nil {
 		p.Children = append(p.Children, child)
 	}
 }
@@ -45,7 +77,7 @@ func (p *Path) Add(path []*regexp.Regexp) *Path {
 		path = path[1:]
 	}
 
-	node, _ := p.findChild(path[0].String())
+	node := p.childNamed(path[0].String())
 	if node == nil {
 		node = new(Path)
 		node.Regexp = path[0]
@@ -56,20 +88,89 @@ func (p *Path) Add(path []*regexp.Regexp) *Path {
 	return node.Add(path[1:])
 }
 
-func (p *Path) Find(path []string, groups map[string]string) (*Path, map[string]string) {
+// Route is a node that a path resolves to, along with the captures made on
+// the way there.
+type Route struct {
+	Node   *Path
+	Groups map[string]string
+	ranks  []int
+}
+
+// the ranks of path segments, from least to most specific.
+const (
+	rankCatchAll = iota + 1
+	rankRegexp
+	rankLiteral
+)
+
+func rank(re *regexp.Regexp) int {
+	if _, complete := re.LiteralPrefix(); complete {
+		return rankLiteral
+	}
+	return rankRegexp
+}
+
+// better orders routes by the ranks of their segments, so that where two
+// routes first differ, the one with the more specific segment wins.
+func (r *Route) better(other *Route) bool {
+	for i := range r.ranks {
+		if i >= len(other.ranks) || r.ranks[i] != other.ranks[i] {
+			return i >= len(other.ranks) || r.ranks[i] > other.ranks[i]
+		}
+	}
+	return false
+}
+
+func (p *Path) resolve(path []string, route Route, routes *[]Route) {
 	if len(path) == 0 {
-		return p, groups
+		route.Node = p
+		*routes = append(*routes, route)
+		return
 	}
 
-	if child, g := p.findChild(path[0]); child != nil {
-		if groups == nil {
-			groups = make(map[string]string)
+	for _, child := range p.Children {
+		re := child.Regexp
+		matched, groups := re.String() == path[0], map[string]string(nil)
+		if !matched {
+			matched, groups = match(re, path[0])
 		}
-		for k, v := range g {
-			groups[k] = v
+		if !matched {
+			continue
 		}
-		return child.Find(path[1:], groups)
+
+		next := Route{
+			Groups: merge(merge(make(map[string]string), route.Groups), groups),
+			ranks:  append(append([]int(nil), route.ranks...), rank(re)),
+		}
+		child.resolve(path[1:], next, routes)
+	}
+
+	// a catch-all needs something to catch.
+	remainder := strings.Join(path, "/")
+	if remainder == "" {
+		return
+	}
+
+	for _, rest := range p.Rest {
+		groups := map[string]string{rest.Name: remainder}
+		*routes = append(*routes, Route{
+			Node:   rest,
+			Groups: merge(merge(make(map[string]string), route.Groups), groups),
+			ranks:  append(append([]int(nil), route.ranks...), rankCatchAll),
+		})
 	}
+}
 
-	return nil, groups
+// Resolve finds every node that path leads to, considering each branch that
+// matches rather than just the first.  Routes are ordered best first: literal
+// segments beat regular expressions, which beat catch-alls, and earlier
+// segments count for more than later ones.  Routes that tie keep the order
+// the tree was built in.
+func (p *Path) Resolve(path []string) []Route {
+	var routes []Route
+	p.resolve(path, Route{Groups: make(map[string]string)}, &routes)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].better(&routes[j])
+	})
+	return routes
 }
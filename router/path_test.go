@@ -5,6 +5,14 @@ import (
 	"testing"
 )
 
+// best gives the node and captures of the best route path resolves to, if any.
+func best(tree *Path, path ...string) (*Path, map[string]string) {
+	if routes := tree.Resolve(path); len(routes) > 0 {
+		return routes[0].Node, routes[0].Groups
+	}
+	return nil, nil
+}
+
 // should find a child in a tree's immediate children
 func TestPathFindChild(t *testing.T) {
 	var tree, child Path
@@ -12,7 +20,7 @@ func TestPathFindChild(t *testing.T) {
 	child.Regexp, _ = regexp.Compile("test")
 	tree.Children = append(tree.Children, &child)
 
-	found, _ := tree.findChild("test")
+	found, _ := best(&tree, "test")
 
 	if found == nil {
 		t.Error("did not find child")
@@ -26,7 +34,7 @@ func TestPathFindMissingChild(t *testing.T) {
 	child.Regexp, _ = regexp.Compile("test")
 	tree.Children = append(tree.Children, &child)
 
-	found, _ := tree.findChild("missing")
+	found, _ := best(&tree, "missing")
 
 	if found != nil {
 		t.Error("found a missing child")
@@ -40,7 +48,7 @@ func TestPathFindRegexChild(t *testing.T) {
 	child.Regexp, _ = regexp.Compile(`\d+`)
 	tree.Children = append(tree.Children, &child)
 
-	found, _ := tree.findChild("123")
+	found, _ := best(&tree, "123")
 
 	if found == nil {
 		t.Error("did not find regular expression node")
@@ -53,7 +61,7 @@ func TestPathFindRegexGroups(t *testing.T) {
 	child.Regexp, _ = regexp.Compile(`(?P<test>a*)`)
 	tree.Children = append(tree.Children, &child)
 
-	found, group := tree.findChild("aaaa")
+	found, group := best(&tree, "aaaa")
 
 	if found == nil {
 		t.Fatal("did not find regular expression node")
@@ -101,7 +109,7 @@ func TestPathAddSameChild(t *testing.T) {
 	tree.Children = append(tree.Children, &child)
 
 	re, _ := regexp.Compile("child")
-	tree.addChild(&Path{re, nil, nil})
+	tree.addChild(&Path{Regexp: re})
 
 	if len(tree.Children) > 1 {
 		t.Error("added child of the same name")
@@ -139,11 +147,11 @@ func TestPathCreatePath(t *testing.T) {
 		t.Error("invalid tree children")
 	}
 
-	test, _ := tree.findChild("test")
+	test := tree.childNamed("test")
 	if test == nil {
 		t.Fatal("could not find \"test\".")
 	}
-	if c, _ := test.findChild("child"); c == nil {
+	if c := test.childNamed("child"); c == nil {
 		t.Error("could not find \"child\".")
 	}
 }
@@ -163,7 +171,7 @@ func TestPathAddToExisting(t *testing.T) {
 
 	tree.Add(res)
 
-	if c, _ := child.findChild("child2"); c == nil {
+	if c := child.childNamed("child2"); c == nil {
 		t.Error("could not find \"child2\".")
 	}
 }
@@ -185,11 +193,11 @@ func TestPathIdentity(t *testing.T) {
 
 	tree.Add(res)
 
-	if c, _ := tree.findChild("child1"); c != &child1 {
+	if c := tree.childNamed("child1"); c != &child1 {
 		t.Error("unexpected child1")
 	}
 
-	if c, _ := child1.findChild("child2"); c != &child2 {
+	if c := child1.childNamed("child2"); c != &child2 {
 		t.Error("unexpected child2")
 	}
 
@@ -231,7 +239,7 @@ func TestPathFindSimple(t *testing.T) {
 
 	tree.addChild(&child)
 
-	found, _ := tree.Find([]string{"child"}, nil)
+	found, _ := best(&tree, "child")
 
 	if found == nil || found.Regexp.String() != "child" {
 		t.Error("did not find \"child\"")
@@ -247,7 +255,7 @@ func TestPathFindDeep(t *testing.T) {
 	tree.addChild(&child1)
 	child1.addChild(&child2)
 
-	found, _ := tree.Find([]string{"child1", "child2"}, nil)
+	found, _ := best(&tree, "child1", "child2")
 
 	if found == nil || found.Regexp.String() != "child2" {
 		t.Error("did not find \"child2\"")
@@ -257,7 +265,7 @@ func TestPathFindDeep(t *testing.T) {
 // should find nothing in an empty tree
 func TestPathFindEmpty(t *testing.T) {
 	var tree Path
-	if found, _ := tree.Find([]string{"test"}, nil); found != nil {
+	if found, _ := best(&tree, "test"); found != nil {
 		t.Error("expected nil when searching empty tree")
 	}
 }
@@ -269,7 +277,7 @@ func TestPathFindMissingPath(t *testing.T) {
 
 	tree.addChild(&child)
 
-	if found, _ := tree.Find([]string{"missing"}, nil); found != nil {
+	if found, _ := best(&tree, "missing"); found != nil {
 		t.Error("expected nil when searching missing path")
 	}
 }
@@ -283,7 +291,7 @@ func TestFindShortPath(t *testing.T) {
 	tree.addChild(&child1)
 	child1.addChild(&child2)
 
-	if found, _ := tree.Find([]string{"child1"}, nil); found != &child1 {
+	if found, _ := best(&tree, "child1"); found != &child1 {
 		t.Error("expected to find child1")
 	}
 }
@@ -297,7 +305,7 @@ func TestPathFindRegexp(t *testing.T) {
 	tree.addChild(&child1)
 	child1.addChild(&child2)
 
-	found, _ := tree.Find([]string{"123", "test"}, nil)
+	found, _ := best(&tree, "123", "test")
 	if found != &child2 {
 		t.Error("did not find \"test\"")
 	}
@@ -311,7 +319,7 @@ func TestPathFindRegexpGroups(t *testing.T) {
 	tree.addChild(&child1)
 	child1.addChild(&child2)
 
-	found, groups := tree.Find([]string{"aaa", "bbb"}, nil)
+	found, groups := best(&tree, "aaa", "bbb")
 	if found != &child2 {
 		t.Fatal("did not find \"test\"")
 	}
@@ -328,3 +336,124 @@ func TestPathFindRegexpGroups(t *testing.T) {
 		t.Errorf("expected \"test2\" to be \"bbb\", was %q", groups["test2"])
 	}
 }
+
+// should order actions by priority, then specificity, then insertion
+func TestPathAddAction(t *testing.T) {
+	var tree Path
+	var actions []*HTTPAction
+
+	for _, script := range []string{
+		`{ "request": { "method": "get" } }`,
+		`{ "request": { "method": "get", "url": "/?a=1" } }`,
+		`{ "request": { "method": "get" }, "priority": 1 }`,
+		`{ "request": { "method": "get" } }`,
+	} {
+		action, err := HTTPActionFromJSON([]byte(script))
+		if err != nil {
+			t.Fatalf("received error (%v)", err)
+		}
+		actions = append(actions, action)
+		tree.AddAction(action)
+	}
+
+	expected := []*HTTPAction{actions[2], actions[1], actions[0], actions[3]}
+	for i, a := range expected {
+		if tree.Actions[i] != a {
+			t.Errorf("unexpected action at %d", i)
+		}
+	}
+}
+
+// should backtrack out of a branch that can't complete the path
+func TestPathResolveBacktrack(t *testing.T) {
+	var tree Path
+	tree.Add([]*regexp.Regexp{
+		regexp.MustCompile(`^users$`),
+		regexp.MustCompile(`^(?:(?P<id>\d+|me))$`),
+		regexp.MustCompile(`^orders$`),
+	})
+	tree.Add([]*regexp.Regexp{
+		regexp.MustCompile(`^users$`),
+		regexp.MustCompile(`^me$`),
+		regexp.MustCompile(`^profile$`),
+	})
+
+	found, groups := best(&tree, "users", "me", "orders")
+	if found == nil || found.Regexp.String() != "^orders$" {
+		t.Fatal("did not find \"orders\"")
+	}
+	if groups["id"] != "me" {
+		t.Errorf("expected \"id\" to be \"me\", was %q", groups["id"])
+	}
+
+	found, _ = best(&tree, "users", "me", "profile")
+	if found == nil || found.Regexp.String() != "^profile$" {
+		t.Error("did not find \"profile\"")
+	}
+}
+
+// should rank literal segments above regular expressions
+func TestPathResolveRank(t *testing.T) {
+	var tree Path
+	tree.Add([]*regexp.Regexp{
+		regexp.MustCompile(`^(?:\w+)$`), regexp.MustCompile(`^b$`),
+	})
+	tree.Add([]*regexp.Regexp{
+		regexp.MustCompile(`^a$`), regexp.MustCompile(`^(?:\w+)$`),
+	})
+
+	routes := tree.Resolve([]string{"a", "b"})
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 routes, got %d", len(routes))
+	}
+
+	if routes[0].Node.Regexp.String() != `^(?:\w+)$` {
+		t.Errorf("expected the literal first segment to win, got %q",
+			routes[0].Node.Regexp)
+	}
+}
+
+// should not add a path beneath a regular expression that matches its text
+func TestPathAddDistinct(t *testing.T) {
+	var tree Path
+	tree.Add([]*regexp.Regexp{regexp.MustCompile(`.+`)})
+	tree.Add([]*regexp.Regexp{regexp.MustCompile(`me`)})
+
+	if len(tree.Children) != 2 {
+		t.Errorf("expected 2 children, got %d", len(tree.Children))
+	}
+}
+
+// should capture the rest of a path with a catch-all
+func TestPathResolveCatchAll(t *testing.T) {
+	var tree Path
+	files := tree.Add([]*regexp.Regexp{regexp.MustCompile(`^files$`)})
+	rest := files.AddCatchAll("key")
+	meta := files.Add([]*regexp.Regexp{
+		regexp.MustCompile(`^files$`),
+		regexp.MustCompile(`^(?:\w+)$`),
+		regexp.MustCompile(`^meta$`),
+	})
+
+	routes := tree.Resolve([]string{"files", "a", "b", "c.txt"})
+	if len(routes) != 1 || routes[0].Node != rest {
+		t.Fatal("expected only the catch-all to match")
+	}
+	if routes[0].Groups["key"] != "a/b/c.txt" {
+		t.Errorf("expected \"key\" to be \"a/b/c.txt\", was %q",
+			routes[0].Groups["key"])
+	}
+
+	routes = tree.Resolve([]string{"files", "a", "meta"})
+	if len(routes) != 2 || routes[0].Node != meta || routes[1].Node != rest {
+		t.Error("expected per-segment children to beat the catch-all")
+	}
+
+	if routes := tree.Resolve([]string{"files"}); len(routes) != 1 ||
+		routes[0].Node != files {
+		t.Error("expected the catch-all to need at least one segment")
+	}
+	if routes := tree.Resolve([]string{"files", ""}); len(routes) != 0 {
+		t.Error("expected the catch-all not to match an empty remainder")
+	}
+}
@@ -0,0 +1,80 @@
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// hole finds the {{/pattern/flags}} regular expressions embedded in otherwise
+// literal script text.
+var hole = regexp.MustCompile(`{{/(.*?)/([imsU]*)}}`)
+
+// splitPattern splits s around each sep that isn't inside a hole, so that a
+// hole's regular expression may itself contain sep.
+func splitPattern(s string, sep string) []string {
+	var parts []string
+	holes := hole.FindAllStringIndex(s, -1)
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		if len(holes) > 0 && i == holes[0][0] {
+			i = holes[0][1] - 1
+			holes = holes[1:]
+		} else if strings.HasPrefix(s[i:], sep) {
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i = start - 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// compilePattern compiles script text into a regular expression matching the
+// whole of a string, or any part of it if partial is set.  Text outside of
+// holes is matched literally, after being passed through unescape if one is
+// given; each hole is matched as the regular expression it holds, with its
+// flags applied.
+func compilePattern(s string, unescape func(string) (string, error),
+	partial bool) (*regexp.Regexp, error) {
+	var b strings.Builder
+	last := 0
+
+	literal := func(text string) error {
+		if unescape != nil {
+			var err error
+			if text, err = unescape(text); err != nil {
+				return err
+			}
+		}
+		b.WriteString(regexp.QuoteMeta(text))
+		return nil
+	}
+
+	if !partial {
+		b.WriteString("^")
+	}
+	for _, loc := range hole.FindAllStringSubmatchIndex(s, -1) {
+		if err := literal(s[last:loc[0]]); err != nil {
+			return nil, err
+		}
+
+		// accept the (?<name>...) group syntax as well as Go's (?P<name>...)
+		pattern := strings.ReplaceAll(s[loc[2]:loc[3]], "(?<", "(?P<")
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(&b, "(?%s:%s)", s[loc[4]:loc[5]], pattern)
+		last = loc[1]
+	}
+	if err := literal(s[last:]); err != nil {
+		return nil, err
+	}
+	if !partial {
+		b.WriteString("$")
+	}
+
+	return regexp.Compile(b.String())
+}
@@ -0,0 +1,69 @@
+package router
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// should split around separators outside of holes only
+func TestPatternSplit(t *testing.T) {
+	result := splitPattern("/a/{{/b/c/i}}/d", "/")
+	expected := []string{"", "a", "{{/b/c/i}}", "d"}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %q, received %q", expected, result)
+	}
+}
+
+// should escape literal text and anchor the pattern
+func TestPatternLiteral(t *testing.T) {
+	re, err := compilePattern("items.json", nil, false)
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	if re.String() != `^items\.json$` {
+		t.Errorf("expected %q, received %q", `^items\.json$`, re)
+	}
+
+	if re.MatchString("itemsXjson") || re.MatchString("all-items.json") {
+		t.Error("expected literal text to match exactly")
+	}
+}
+
+// should embed holes as regular expression with their flags
+func TestPatternHole(t *testing.T) {
+	re, err := compilePattern("user-{{/(?<user_id>[a-z]+)/i}}.json", nil, false)
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	matched, groups := match(re, "user-ABC.json")
+	if !matched {
+		t.Fatal("expected a match, got none")
+	}
+
+	if groups["user_id"] != "ABC" {
+		t.Errorf("expected \"user_id\" to be \"ABC\", was %q", groups["user_id"])
+	}
+}
+
+// should return an error for an invalid hole
+func TestPatternHoleError(t *testing.T) {
+	if _, err := compilePattern("{{/a)|(b/}}", nil, false); err == nil {
+		t.Error("error should not be nil")
+	}
+}
+
+// should unescape literal text, but not holes
+func TestPatternUnescape(t *testing.T) {
+	re, err := compilePattern("a%20b{{/%+/}}", url.QueryUnescape, false)
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	if !re.MatchString("a b%%") {
+		t.Errorf("expected %q to match \"a b%%%%\"", re)
+	}
+}
@@ -0,0 +1,48 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+type Passthrough struct {
+	Upstream *url.URL
+	proxy    *httputil.ReverseProxy
+}
+
+func NewPassthrough(upstream string) (*Passthrough, error) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, err
+	} else if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("upstream must be an absolute URL")
+	}
+
+	p := &Passthrough{Upstream: u}
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(u)
+			r.SetXForwarded()
+		},
+		// flush as soon as the upstream writes, so streamed responses stay
+		// streamed.
+		FlushInterval: -1,
+	}
+
+	return p, nil
+}
+
+// Forward sends the request on to the upstream, preserving its method, path,
+// query, headers and body, and copies the upstream response back to w.
+func (p *Passthrough) Forward(w http.ResponseWriter, req *http.Request) {
+	p.proxy.ServeHTTP(w, req)
+}
+
+// Observe registers f to see each upstream response before it is copied back
+// to the client.  f may replace the response's body, but must leave it
+// readable.
+func (p *Passthrough) Observe(f func(*http.Response) error) {
+	p.proxy.ModifyResponse = f
+}
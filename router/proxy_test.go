@@ -0,0 +1,98 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// should correctly parse a passthrough upstream
+func TestHTTPActionPassthrough(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get",
+			"path": "/test"
+		},
+		"passthrough": {
+			"upstream": "https://api.vendor.test"
+		}
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	if result.Passthrough == nil {
+		t.Fatal("passthrough is nil")
+	}
+
+	upstream := result.Passthrough.Upstream.String()
+	if upstream != "https://api.vendor.test" {
+		t.Errorf("expected \"https://api.vendor.test\", received %q", upstream)
+	}
+}
+
+// should return an error when the upstream is not an absolute URL
+func TestHTTPActionPassthroughRelative(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get"
+		},
+		"passthrough": {
+			"upstream": "/relative"
+		}
+	}`))
+
+	if err == nil {
+		t.Error("error should not be nil")
+	}
+
+	if result != nil {
+		t.Error("expected result to be nil")
+	}
+}
+
+// should forward the method, path, query, headers and body upstream
+func TestPassthroughForward(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			w.Header().Set("x-method", req.Method)
+			w.Header().Set("x-uri", req.URL.RequestURI())
+			w.Header().Set("x-test", req.Header.Get("x-test"))
+			w.WriteHeader(201)
+			w.Write(body)
+		}))
+	defer upstream.Close()
+
+	p, err := NewPassthrough(upstream.URL + "/base")
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	req := httptest.NewRequest("POST", "/users?id=1", strings.NewReader("data"))
+	req.Header.Set("x-test", "header")
+	w := httptest.NewRecorder()
+	p.Forward(w, req)
+
+	expected := map[string]string{
+		"x-method": "POST",
+		"x-uri":    "/base/users?id=1",
+		"x-test":   "header",
+	}
+	for key, value := range expected {
+		if w.Header().Get(key) != value {
+			t.Errorf("expected %q to be %q, was %q", key, value, w.Header().Get(key))
+		}
+	}
+
+	if w.Code != 201 {
+		t.Errorf("expected status to be 201, got %d", w.Code)
+	}
+
+	if w.Body.String() != "data" {
+		t.Errorf("expected body to be \"data\", received %q", w.Body.String())
+	}
+}
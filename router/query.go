@@ -0,0 +1,157 @@
+package router
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+)
+
+// Query matches one value of a query parameter.  A script that repeats a key
+// expects the request to repeat it as well, with each Query matching a
+// different value.
+type Query struct {
+	Key      string
+	Value    *regexp.Regexp
+	Optional bool
+}
+
+// queryMatcher parses one matcher from a script's query object: a string in
+// the url syntax, or an object giving a "value" in that syntax or a raw
+// "regex", whether the parameter is "optional", and whether a "partial" match
+// of the value is enough.
+func queryMatcher(key string, spec any) (Query, error) {
+	var err error
+	q := Query{Key: key}
+
+	switch v := spec.(type) {
+	case string:
+		q.Value, err = compilePattern(v, nil, false)
+	case map[string]any:
+		value, hasValue := v["value"].(string)
+		regex, hasRegex := v["regex"].(string)
+		partial, _ := v["partial"].(bool)
+		q.Optional, _ = v["optional"].(bool)
+
+		switch {
+		case hasValue && hasRegex:
+			err = fmt.Errorf("query %q has both a value and a regex", key)
+		case hasRegex:
+			q.Value, err = compile(regex, partial)
+		default:
+			q.Value, err = compilePattern(value, nil, partial)
+		}
+	default:
+		err = fmt.Errorf("query %q has an unrecognized matcher", key)
+	}
+
+	return q, err
+}
+
+// queryMatchers parses an object of query matchers, in which a list expects
+// its key to be repeated.
+func queryMatchers(specs map[string]any) ([]Query, error) {
+	var keys []string
+	var queries []Query
+	for k := range specs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		list, ok := specs[k].([]any)
+		if !ok {
+			list = []any{specs[k]}
+		}
+
+		for _, spec := range list {
+			if q, err := queryMatcher(k, spec); err != nil {
+				return nil, err
+			} else {
+				queries = append(queries, q)
+			}
+		}
+	}
+
+	return queries, nil
+}
+
+func requestQuery(action *HTTPAction, parsed *httpJSON) error {
+	queries, err := queryMatchers(parsed.Request.Query)
+	action.Request.Query = append(action.Request.Query, queries...)
+	return err
+}
+
+// assign matches each of queries to a different one of values, trying every
+// combination until one works.  used marks the values already taken.
+func assign(queries []Query, values []string, used []bool) (bool,
+	map[string]string) {
+	if len(queries) == 0 {
+		return true, make(map[string]string)
+	}
+
+	for i, v := range values {
+		if used[i] {
+			continue
+		}
+
+		if matched, groups := match(queries[0].Value, v); matched {
+			used[i] = true
+			if ok, rest := assign(queries[1:], values, used); ok {
+				return true, merge(rest, groups)
+			}
+			used[i] = false
+		}
+	}
+
+	return false, nil
+}
+
+// compareValues matches a set of values, such as a query string or form,
+// against queries.  Every key the queries require must be present with
+// matching values, in any order; keys they don't name are ignored.
+func compareValues(queries []Query, values url.Values) (bool,
+	map[string]string) {
+	vars := make(map[string]string)
+	byKey := make(map[string][]Query)
+	var keys []string
+
+	for _, q := range queries {
+		if _, ok := byKey[q.Key]; !ok {
+			keys = append(keys, q.Key)
+		}
+		byKey[q.Key] = append(byKey[q.Key], q)
+	}
+
+	for _, k := range keys {
+		var required, optional []Query
+		for _, q := range byKey[k] {
+			if q.Optional {
+				optional = append(optional, q)
+			} else {
+				required = append(required, q)
+			}
+		}
+
+		// optional matchers only apply to values beyond those the required
+		// ones need, so an optional parameter may be absent but must match
+		// when present.
+		v := values[k]
+		if extra := len(v) - len(required); extra > 0 {
+			required = append(required, optional[:min(extra, len(optional))]...)
+		}
+
+		matched, groups := assign(required, v, make([]bool, len(v)))
+		if !matched {
+			return false, nil
+		}
+		merge(vars, groups)
+	}
+
+	return true, vars
+}
+
+// CompareQuery matches the request's query string against the script's.
+func (a *HTTPAction) CompareQuery(query url.Values) (bool, map[string]string) {
+	return compareValues(a.Request.Query, query)
+}
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"net/url"
+	"testing"
+)
+
+// should correctly parse each kind of query matcher
+func TestHTTPActionRequestQuery(t *testing.T) {
+	result := requestAction(t, "get", `"query": {
+		"exact": "a.b",
+		"hole": "{{/\\d+/}}",
+		"regex": { "regex": "a|b", "optional": true },
+		"repeated": ["a", "b"]
+	}`)
+
+	expected := []Query{
+		{"exact", nil, false},
+		{"hole", nil, false},
+		{"regex", nil, true},
+		{"repeated", nil, false},
+		{"repeated", nil, false},
+	}
+	values := []string{`^a\.b$`, `^(?:\d+)$`, `^(?:a|b)$`, `^a$`, `^b$`}
+
+	if len(result.Request.Query) != len(expected) {
+		t.Fatalf("expected %d matchers, got %d", len(expected),
+			len(result.Request.Query))
+	}
+
+	for i, q := range result.Request.Query {
+		if q.Key != expected[i].Key || q.Optional != expected[i].Optional {
+			t.Errorf("expected %+v, received %+v", expected[i], q)
+		}
+		if q.Value.String() != values[i] {
+			t.Errorf("expected %q, received %q", values[i], q.Value)
+		}
+	}
+}
+
+// should return an error for a matcher with both a value and a regex
+func TestHTTPActionRequestQueryError(t *testing.T) {
+	_, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "get",
+			"query": { "id": { "value": "1", "regex": "1" } }
+		}
+	}`))
+
+	if err == nil {
+		t.Error("error should not be nil")
+	}
+}
+
+// should match query parameters regardless of order
+func TestCompareQueryOrder(t *testing.T) {
+	result := requestAction(t, "get", `"query": {
+		"tag": ["{{/(?<first>a.*)/}}", "{{/(?<second>b.*)/}}"]
+	}`)
+
+	matched, vars := result.CompareQuery(url.Values{"tag": {"bbb", "aaa"}})
+	if !matched {
+		t.Fatal("expected query to match")
+	}
+
+	if vars["first"] != "aaa" || vars["second"] != "bbb" {
+		t.Errorf("unexpected captures %v", vars)
+	}
+}
+
+// should require a value for every repeated matcher
+func TestCompareQueryRepeated(t *testing.T) {
+	result := requestAction(t, "get", `"query": { "tag": ["{{/.*/}}", "{{/.*/}}"] }`)
+
+	if matched, _ := result.CompareQuery(url.Values{"tag": {"a"}}); matched {
+		t.Error("expected one value not to satisfy two matchers")
+	}
+
+	if matched, _ := result.CompareQuery(url.Values{"tag": {"a", "b"}}); !matched {
+		t.Error("expected two values to satisfy two matchers")
+	}
+}
+
+// should allow optional parameters to be absent, but not to mismatch
+func TestCompareQueryOptional(t *testing.T) {
+	result := requestAction(t, "get", `"query": {
+		"page": { "value": "{{/\\d+/}}", "optional": true }
+	}`)
+
+	tests := []struct {
+		query    url.Values
+		expected bool
+	}{
+		{url.Values{}, true},
+		{url.Values{"page": {"2"}}, true},
+		{url.Values{"page": {"two"}}, false},
+	}
+
+	for _, test := range tests {
+		if matched, _ := result.CompareQuery(test.query); matched != test.expected {
+			t.Errorf("expected %v for %v, got %v", test.expected, test.query,
+				matched)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// headers that describe a single transfer rather than the response itself, and
+// so never belong in a recorded script.
+var transferHeaders = []string{
+	"Connection", "Content-Length", "Keep-Alive", "Transfer-Encoding",
+}
+
+func recordHeaders(header http.Header, strip []string) map[string]string {
+	header = header.Clone()
+	for _, h := range append(strip, transferHeaders...) {
+		header.Del(h)
+	}
+
+	if len(header) == 0 {
+		return nil
+	}
+
+	headers := make(map[string]string)
+	for k, v := range header {
+		headers[strings.ToLower(k)] = strings.Join(v, ", ")
+	}
+	return headers
+}
+
+// ScriptFromExchange turns a proxied request and its response into a mocking
+// script that HTTPActionFromJSON will accept.  The request's url is recorded
+// literally and its body is escaped, so the script only matches the request
+// that was recorded.  The response body is kept as JSON or text where it can
+// be, and in base64 otherwise.  Any response headers named in strip are left
+// out.
+func ScriptFromExchange(req *http.Request, reqBody []byte, res *http.Response,
+	resBody []byte, strip []string) ([]byte, error) {
+	var script httpJSON
+
+	script.Request.Method = strings.ToLower(req.Method)
+	script.Request.URL = req.URL.EscapedPath()
+	if req.URL.RawQuery != "" {
+		script.Request.URL += "?" + req.URL.RawQuery
+	}
+	if len(reqBody) > 0 {
+		script.Request.Body = "^" + regexp.QuoteMeta(string(reqBody)) + "$"
+	}
+
+	script.Response.Status = res.StatusCode
+	script.Response.Headers = recordHeaders(res.Header, strip)
+	switch {
+	case len(resBody) == 0:
+		// a script without a body sends "null", so say there's none.
+		script.Response.BodyText = new(string)
+	case bytes.Contains(resBody, []byte("{{")) || !utf8.Valid(resBody):
+		// it would be rendered as a template, or can't be written as text.
+		script.Response.BodyBase64 = base64.StdEncoding.EncodeToString(resBody)
+	case json.Valid(resBody):
+		script.Response.Body = json.RawMessage(resBody)
+	default:
+		text := string(resBody)
+		script.Response.BodyText = &text
+	}
+
+	return json.MarshalIndent(script, "", "    ")
+}
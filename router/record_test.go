@@ -0,0 +1,116 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func recordedAction(t *testing.T, req *http.Request, reqBody string,
+	res *http.Response, resBody string, strip []string) *HTTPAction {
+	script, err := ScriptFromExchange(req, []byte(reqBody), res,
+		[]byte(resBody), strip)
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	action, err := HTTPActionFromJSON(script)
+	if err != nil {
+		t.Fatalf("recorded script did not parse (%v)\n%s", err, script)
+	}
+	return action
+}
+
+// should record a request as an escaped, matching route
+func TestRecordRequest(t *testing.T) {
+	req := httptest.NewRequest("POST", "/v1/items.json?id=1", nil)
+	res := &http.Response{StatusCode: 200, Header: http.Header{}}
+	action := recordedAction(t, req, "a+b", res, "", nil)
+
+	expected := []string{"^post$", `^v1$`, `^items\.json$`}
+	if len(action.Request.Path) != len(expected) {
+		t.Fatalf("expected a path length of %d, got %d", len(expected),
+			len(action.Request.Path))
+	}
+	for i, s := range expected {
+		if action.Request.Path[i].String() != s {
+			t.Errorf("expected %q, received %q", s, action.Request.Path[i])
+		}
+	}
+
+	if matched, _ := action.CompareQuery(req.URL.Query()); !matched {
+		t.Error("expected recorded query to match the original")
+	}
+
+	if matched, _ := action.CompareBody("a+b"); !matched {
+		t.Error("expected recorded body to match the original")
+	}
+	for _, body := range []string{"a+bc", "xa+b", "aab"} {
+		if matched, _ := action.CompareBody(body); matched {
+			t.Errorf("expected recorded body not to match %q", body)
+		}
+	}
+}
+
+// should record the response status, headers and body
+func TestRecordResponse(t *testing.T) {
+	req := httptest.NewRequest("GET", "/test", nil)
+	res := &http.Response{StatusCode: 201, Header: http.Header{
+		"Content-Type":   {"application/json"},
+		"Content-Length": {"11"},
+		"Date":           {"Mon, 01 Jan 2024 00:00:00 GMT"},
+	}}
+	action := recordedAction(t, req, "", res, `{"id": 123}`, []string{"date"})
+
+	if action.Response.Status != 201 {
+		t.Errorf("expected status to be 201, got %d", action.Response.Status)
+	}
+
+	expected := map[string]string{"content-type": "application/json"}
+	if len(action.Response.Headers) != len(expected) {
+		t.Errorf("expected %d headers, got %v", len(expected),
+			action.Response.Headers)
+	}
+	for key, value := range expected {
+		if action.Response.Headers[key] != value {
+			t.Errorf("expected %q to be %q, was %q", key, value,
+				action.Response.Headers[key])
+		}
+	}
+
+	if string(action.Response.Body) != `{"id":123}` {
+		t.Errorf("expected body to be %q, received %q", `{"id":123}`,
+			action.Response.Body)
+	}
+}
+
+// should record text and binary response bodies so they're sent back verbatim
+func TestRecordRawBody(t *testing.T) {
+	req := httptest.NewRequest("GET", "/test", nil)
+
+	for _, body := range []string{"plain text", "\x89PNG\x00\xff", "{{ raw }}"} {
+		res := &http.Response{StatusCode: 200, Header: http.Header{}}
+		action := recordedAction(t, req, "", res, body, nil)
+
+		w := httptest.NewRecorder()
+		action.Write(w, httptest.NewRequest("GET", "/test", nil), nil)
+		if w.Body.String() != body {
+			t.Errorf("expected body to be %q, received %q", body,
+				w.Body.String())
+		}
+	}
+}
+
+// should replay an empty response body as empty
+func TestRecordEmptyBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "/test", nil)
+	res := &http.Response{StatusCode: 201, Header: http.Header{}}
+	action := recordedAction(t, req, "", res, "", nil)
+
+	w := httptest.NewRecorder()
+	action.Write(w, httptest.NewRequest("POST", "/test", nil), nil)
+	if w.Code != 201 || w.Body.Len() != 0 {
+		t.Errorf("expected an empty 201, received %d %q", w.Code,
+			w.Body.String())
+	}
+}
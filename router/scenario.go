@@ -0,0 +1,195 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ScenarioStarted is the state every scenario begins in, and returns to when
+// it's reset.
+const ScenarioStarted = "started"
+
+// ScenariosPath is where the state of every scenario can be inspected, set
+// and reset.
+const ScenariosPath = "/__mocket/scenarios"
+
+// Scenario ties a script to a named state machine: the script only answers
+// while the scenario is in State, if one is given, and moves it to Next, if
+// one is given, as it does.
+type Scenario struct {
+	Name  string
+	State string
+	Next  string
+}
+
+type scenarioJSON struct {
+	Name  string `json:"name"`
+	State string `json:"state,omitempty"`
+	Next  string `json:"next,omitempty"`
+}
+
+func scenario(action *HTTPAction, parsed *httpJSON) error {
+	if parsed.Scenario == nil {
+		return nil
+	} else if parsed.Scenario.Name == "" {
+		return errors.New("scenario needs a name")
+	}
+
+	action.Scenario = &Scenario{
+		Name:  parsed.Scenario.Name,
+		State: parsed.Scenario.State,
+		Next:  parsed.Scenario.Next,
+	}
+	return nil
+}
+
+// Scenarios holds the current state of every scenario the scripts name.
+type Scenarios struct {
+	mu     sync.Mutex
+	states map[string]string
+}
+
+func NewScenarios() *Scenarios {
+	return &Scenarios{states: make(map[string]string)}
+}
+
+// Declare sets the scenarios to those named, starting any that are new and
+// forgetting any that are no longer named.  Scenarios that remain keep their
+// state, so that reloading scripts doesn't interrupt a flow.
+func (s *Scenarios) Declare(names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make(map[string]string)
+	for _, name := range names {
+		if state, ok := s.states[name]; ok {
+			states[name] = state
+		} else {
+			states[name] = ScenarioStarted
+		}
+	}
+	s.states = states
+}
+
+// State gives the current state of a scenario, and whether it exists.
+func (s *Scenarios) State(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[name]
+	return state, ok
+}
+
+// Set moves a scenario to a state, reporting whether the scenario exists.
+func (s *Scenarios) Set(name string, state string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.states[name]; !ok {
+		return false
+	}
+	s.states[name] = state
+	return true
+}
+
+// Reset returns a scenario to its starting state, reporting whether it exists.
+func (s *Scenarios) Reset(name string) bool {
+	return s.Set(name, ScenarioStarted)
+}
+
+// ResetAll returns every scenario to its starting state.
+func (s *Scenarios) ResetAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range s.states {
+		s.states[name] = ScenarioStarted
+	}
+}
+
+// All gives the current state of every scenario, by name.
+func (s *Scenarios) All() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make(map[string]string)
+	for name, state := range s.states {
+		states[name] = state
+	}
+	return states
+}
+
+// Claim reports whether a script's scenario, if it has one, is in the state
+// the script requires, and if so moves it on to the script's next state.  Both
+// happen under one lock, so that of two requests racing for a transition, only
+// one takes it.
+func (s *Scenarios) Claim(a *HTTPAction) bool {
+	if a.Scenario == nil {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[a.Scenario.Name]
+	if a.Scenario.State != "" && state != a.Scenario.State {
+		return false
+	} else if ok && a.Scenario.Next != "" {
+		s.states[a.Scenario.Name] = a.Scenario.Next
+	}
+	return true
+}
+
+type scenarioStateJSON struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+}
+
+// HandleAdmin answers requests beneath ScenariosPath.  The path itself lists
+// every scenario's state, or resets them all on DELETE; beneath it, each
+// scenario's state can be read, set with PUT and reset with DELETE.
+func (s *Scenarios) HandleAdmin(w http.ResponseWriter, req *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(req.URL.Path, ScenariosPath), "/")
+
+	switch {
+	case name == "" && req.Method == http.MethodGet:
+		writeJSON(w, 200, s.All())
+	case name == "" && req.Method == http.MethodDelete:
+		s.ResetAll()
+		w.WriteHeader(204)
+	case name == "":
+		w.WriteHeader(405)
+	case req.Method == http.MethodGet:
+		if state, ok := s.State(name); !ok {
+			w.WriteHeader(404)
+		} else {
+			writeJSON(w, 200, scenarioStateJSON{name, state})
+		}
+	case req.Method == http.MethodPut:
+		var body scenarioStateJSON
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil ||
+			body.State == "" {
+			w.WriteHeader(400)
+		} else if !s.Set(name, body.State) {
+			w.WriteHeader(404)
+		} else {
+			writeJSON(w, 200, scenarioStateJSON{name, body.State})
+		}
+	case req.Method == http.MethodDelete:
+		if !s.Reset(name) {
+			w.WriteHeader(404)
+		} else {
+			w.WriteHeader(204)
+		}
+	default:
+		w.WriteHeader(405)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, _ := json.Marshal(v)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,167 @@
+package router
+
+import (
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func scenarioScript(t *testing.T, scenario string) *HTTPAction {
+	return parseAction(t, `{
+		"request": { "method": "get", "url": "/orders/1" },
+		"response": { "status": 200 },
+		"scenario": `+scenario+`
+	}`)
+}
+
+// should parse a script's scenario, counting a required state as a constraint
+func TestScenarioFromJSON(t *testing.T) {
+	result := scenarioScript(t,
+		`{ "name": "order", "state": "pending", "next": "paid" }`)
+
+	expected := Scenario{Name: "order", State: "pending", Next: "paid"}
+	if result.Scenario == nil || *result.Scenario != expected {
+		t.Errorf("expected scenario %v, received %v", expected,
+			result.Scenario)
+	}
+	if result.Specificity() != 1 {
+		t.Errorf("expected specificity 1, received %d", result.Specificity())
+	}
+
+	_, err := HTTPActionFromJSON([]byte(`{
+		"request": { "method": "get", "url": "/orders/1" },
+		"response": { "status": 200 },
+		"scenario": { "state": "pending" }
+	}`))
+	if err == nil {
+		t.Error("expected an error for a scenario without a name")
+	}
+}
+
+// should only let scripts in the required state answer, moving them on
+func TestScenarioTransitions(t *testing.T) {
+	pending := scenarioScript(t,
+		`{ "name": "order", "state": "started", "next": "paid" }`)
+	paid := scenarioScript(t,
+		`{ "name": "order", "state": "paid", "next": "shipped" }`)
+	stateless := scenarioScript(t, `{ "name": "order" }`)
+
+	s := NewScenarios()
+	s.Declare([]string{"order", "order"})
+
+	if s.Claim(paid) || !s.Claim(stateless) {
+		t.Fatal("expected only scripts for the started state to answer")
+	}
+	if state, _ := s.State("order"); state != ScenarioStarted {
+		t.Errorf("expected state to stay %q, received %q", ScenarioStarted,
+			state)
+	}
+
+	if !s.Claim(pending) {
+		t.Fatal("expected the started script to answer")
+	}
+	if state, _ := s.State("order"); state != "paid" {
+		t.Errorf("expected state \"paid\", received %q", state)
+	}
+	if s.Claim(pending) || !s.Claim(paid) {
+		t.Error("expected only the paid script to answer")
+	}
+}
+
+// should let only one of several racing requests take a transition
+func TestScenarioClaimRace(t *testing.T) {
+	pending := scenarioScript(t,
+		`{ "name": "order", "state": "started", "next": "paid" }`)
+
+	s := NewScenarios()
+	s.Declare([]string{"order"})
+
+	var wg sync.WaitGroup
+	var claimed atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.Claim(pending) {
+				claimed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if claimed.Load() != 1 {
+		t.Errorf("expected one request to take the transition, %d did",
+			claimed.Load())
+	}
+}
+
+// should keep the state of scenarios that are declared again, and forget
+// the rest
+func TestScenarioDeclare(t *testing.T) {
+	s := NewScenarios()
+	s.Declare([]string{"order", "cart"})
+	s.Set("order", "paid")
+	s.Set("cart", "full")
+
+	s.Declare([]string{"order", "user"})
+	expected := map[string]string{"order": "paid", "user": ScenarioStarted}
+	all := s.All()
+	if len(all) != len(expected) {
+		t.Errorf("expected %v, received %v", expected, all)
+	}
+	for name, state := range expected {
+		if all[name] != state {
+			t.Errorf("expected %q to be %q, received %q", name, state,
+				all[name])
+		}
+	}
+
+	if s.Set("cart", "full") {
+		t.Error("expected a forgotten scenario not to be set")
+	}
+}
+
+// should inspect, set and reset scenarios through the admin endpoints
+func TestScenarioAdmin(t *testing.T) {
+	s := NewScenarios()
+	s.Declare([]string{"cart", "order"})
+
+	cases := []struct {
+		method, path, body string
+		status             int
+		response           string
+	}{
+		{"GET", "", "", 200, `{"cart":"started","order":"started"}`},
+		{"PUT", "/order", `{"state":"paid"}`, 200,
+			`{"name":"order","state":"paid"}`},
+		{"GET", "/order", "", 200, `{"name":"order","state":"paid"}`},
+		{"PUT", "/order", `{}`, 400, ""},
+		{"PUT", "/missing", `{"state":"paid"}`, 404, ""},
+		{"GET", "/missing", "", 404, ""},
+		{"DELETE", "/order", "", 204, ""},
+		{"GET", "/order", "", 200, `{"name":"order","state":"started"}`},
+		{"PUT", "/cart", `{"state":"full"}`, 200,
+			`{"name":"cart","state":"full"}`},
+		{"DELETE", "", "", 204, ""},
+		{"GET", "", "", 200, `{"cart":"started","order":"started"}`},
+		{"POST", "", "", 405, ""},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, ScenariosPath+c.path,
+			strings.NewReader(c.body))
+		w := httptest.NewRecorder()
+		s.HandleAdmin(w, req)
+
+		if w.Code != c.status {
+			t.Errorf("%s %s: expected status %d, received %d", c.method,
+				c.path, c.status, w.Code)
+		}
+		if c.response != "" && w.Body.String() != c.response {
+			t.Errorf("%s %s: expected %s, received %s", c.method, c.path,
+				c.response, w.Body.String())
+		}
+	}
+}
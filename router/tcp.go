@@ -0,0 +1,220 @@
+package router
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"time"
+)
+
+// TCPStep is one step of a TCP conversation.  Exactly one of its fields is
+// set.  Literal expects are matched as bytes, since protocols needn't be text;
+// only regular expressions are matched as runes.
+type TCPStep struct {
+	Expect      *regexp.Regexp
+	ExpectBytes []byte
+	Send        []byte
+	Wait        time.Duration
+	Close       bool
+	Reset       bool
+}
+
+type TCPScript struct {
+	Port    string
+	Timeout time.Duration
+	Steps   []TCPStep
+}
+
+type tcpJSON struct {
+	TCP struct {
+		Port    string `json:"port"`
+		Timeout string `json:"timeout"`
+		Steps   []struct {
+			Expect      *string `json:"expect"`
+			ExpectHex   *string `json:"expectHex"`
+			ExpectRegex *string `json:"expectRegex"`
+			Send        *string `json:"send"`
+			SendHex     *string `json:"sendHex"`
+			Wait        *string `json:"wait"`
+			Close       bool    `json:"close"`
+			Reset       bool    `json:"reset"`
+		} `json:"steps"`
+	} `json:"tcp"`
+}
+
+func tcpStep(i int, parsed *tcpJSON) (TCPStep, error) {
+	var step TCPStep
+	var err error
+	s := parsed.TCP.Steps[i]
+	set := 0
+
+	if s.Expect != nil {
+		step.ExpectBytes = []byte(*s.Expect)
+		set++
+	}
+	if s.ExpectHex != nil {
+		step.ExpectBytes, err = hex.DecodeString(*s.ExpectHex)
+		set++
+	}
+	if s.ExpectRegex != nil {
+		step.Expect, err = regexp.Compile(*s.ExpectRegex)
+		set++
+	}
+	if s.Send != nil {
+		step.Send = []byte(*s.Send)
+		set++
+	}
+	if s.SendHex != nil {
+		step.Send, err = hex.DecodeString(*s.SendHex)
+		set++
+	}
+	if s.Wait != nil {
+		step.Wait, err = time.ParseDuration(*s.Wait)
+		set++
+	}
+	if s.Close {
+		step.Close = true
+		set++
+	}
+	if s.Reset {
+		step.Reset = true
+		set++
+	}
+
+	if err != nil {
+		return step, fmt.Errorf("step %d: %w", i, err)
+	} else if set != 1 {
+		return step, fmt.Errorf("step %d: expected exactly one action", i)
+	}
+
+	return step, nil
+}
+
+func TCPScriptFromJSON(input []byte) (*TCPScript, error) {
+	var parsed tcpJSON
+	var err error
+	script := new(TCPScript)
+
+	if err = json.Unmarshal(input, &parsed); err != nil {
+		return nil, err
+	}
+
+	if script.Port = parsed.TCP.Port; script.Port == "" {
+		return nil, errors.New("tcp script has no port")
+	}
+
+	if parsed.TCP.Timeout != "" {
+		if script.Timeout, err = time.ParseDuration(parsed.TCP.Timeout); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range parsed.TCP.Steps {
+		if step, err := tcpStep(i, &parsed); err != nil {
+			return nil, err
+		} else {
+			script.Steps = append(script.Steps, step)
+		}
+	}
+
+	return script, nil
+}
+
+// conversation is the state of one connection running a script: the bytes
+// read but not yet consumed by an expect step, and the captures so far.
+type conversation struct {
+	conn net.Conn
+	buf  []byte
+	vars map[string]string
+}
+
+// find looks for what a step expects in what has been received, giving the
+// end of the match and recording its captures.
+func (c *conversation) find(step *TCPStep) (int, bool) {
+	if step.Expect == nil {
+		i := bytes.Index(c.buf, step.ExpectBytes)
+		return i + len(step.ExpectBytes), i >= 0
+	}
+
+	loc := step.Expect.FindSubmatchIndex(c.buf)
+	if loc == nil {
+		return 0, false
+	}
+
+	matches := make([]string, len(loc)/2)
+	for i := range matches {
+		if loc[2*i] >= 0 {
+			matches[i] = string(c.buf[loc[2*i]:loc[2*i+1]])
+		}
+	}
+	merge(c.vars, captures(step.Expect, matches))
+	return loc[1], true
+}
+
+// expect reads from the connection until what the step expects has been
+// received, then consumes everything up to the end of it.
+func (c *conversation) expect(step *TCPStep, timeout time.Duration) error {
+	chunk := make([]byte, 4096)
+
+	for {
+		if end, ok := c.find(step); ok {
+			c.buf = c.buf[end:]
+			return nil
+		}
+
+		if timeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(timeout))
+		}
+		n, err := c.conn.Read(chunk)
+		c.buf = append(c.buf, chunk[:n]...)
+		if err == io.EOF && n > 0 {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("expecting %q: %w", step.expected(), err)
+		}
+	}
+}
+
+// expected describes what a step expects, for errors.
+func (step *TCPStep) expected() string {
+	if step.Expect != nil {
+		return step.Expect.String()
+	}
+	return string(step.ExpectBytes)
+}
+
+// Run plays the script against conn, closing it once the steps run out.
+func (s *TCPScript) Run(conn net.Conn) error {
+	c := conversation{conn: conn, vars: make(map[string]string)}
+	defer conn.Close()
+
+	for _, step := range s.Steps {
+		switch {
+		case step.Expect != nil || step.ExpectBytes != nil:
+			if err := c.expect(&step, s.Timeout); err != nil {
+				return err
+			}
+		case step.Send != nil:
+			if _, err := conn.Write(replace(step.Send, c.vars)); err != nil {
+				return err
+			}
+		case step.Close:
+			return nil
+		case step.Reset:
+			// with no linger, closing sends a RST instead of a FIN.
+			if tcp, ok := conn.(*net.TCPConn); ok {
+				tcp.SetLinger(0)
+			}
+			return nil
+		default:
+			time.Sleep(step.Wait)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package router
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+// should correctly parse each kind of step
+func TestTCPScriptSteps(t *testing.T) {
+	result, err := TCPScriptFromJSON([]byte(`{
+		"tcp": {
+			"port": "9100",
+			"timeout": "1s",
+			"steps": [
+				{ "expect": "HELLO\r\n" },
+				{ "expectHex": "02" },
+				{ "expectRegex": "AMOUNT=(?P<amount>\\d+)\n" },
+				{ "send": "OK\n" },
+				{ "sendHex": "0203" },
+				{ "wait": "10ms" },
+				{ "close": true },
+				{ "reset": true }
+			]
+		}
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	if result.Port != "9100" || result.Timeout != time.Second {
+		t.Errorf("unexpected port or timeout (%q, %v)", result.Port,
+			result.Timeout)
+	}
+
+	steps := result.Steps
+	if len(steps) != 8 {
+		t.Fatalf("expected 8 steps, got %d", len(steps))
+	}
+
+	if string(steps[0].ExpectBytes) != "HELLO\r\n" {
+		t.Errorf("expected a literal expect, got %q", steps[0].ExpectBytes)
+	}
+	if string(steps[1].ExpectBytes) != "\x02" {
+		t.Errorf("expected a decoded expect, got %q", steps[1].ExpectBytes)
+	}
+	if steps[2].Expect == nil {
+		t.Error("expected a regular expression expect")
+	}
+	if string(steps[4].Send) != "\x02\x03" {
+		t.Errorf("expected decoded bytes, got %q", steps[4].Send)
+	}
+	if steps[5].Wait != 10*time.Millisecond {
+		t.Errorf("expected a wait of 10ms, got %v", steps[5].Wait)
+	}
+	if !steps[6].Close || !steps[7].Reset {
+		t.Error("expected close and reset steps")
+	}
+}
+
+// should return an error when a step has more than one action
+func TestTCPScriptAmbiguousStep(t *testing.T) {
+	result, err := TCPScriptFromJSON([]byte(`{
+		"tcp": {
+			"port": "9100",
+			"steps": [{ "send": "a", "close": true }]
+		}
+	}`))
+
+	if err == nil {
+		t.Error("error should not be nil")
+	}
+
+	if result != nil {
+		t.Error("expected result to be nil")
+	}
+}
+
+// should return an error when no port is given
+func TestTCPScriptNoPort(t *testing.T) {
+	if _, err := TCPScriptFromJSON([]byte(`{"tcp": {}}`)); err == nil {
+		t.Error("error should not be nil")
+	}
+}
+
+// should feed expect captures into later sends
+func TestTCPScriptRun(t *testing.T) {
+	script, err := TCPScriptFromJSON([]byte(`{
+		"tcp": {
+			"port": "9100",
+			"timeout": "1s",
+			"steps": [
+				{ "expectRegex": "AMOUNT=(?P<amount>\\d+)\n" },
+				{ "send": "APPROVED {{amount}}\n" }
+			]
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	client, server := net.Pipe()
+	done := make(chan error)
+	go func() { done <- script.Run(server) }()
+
+	client.Write([]byte("AMOUNT=125\n"))
+	reply, _ := bufio.NewReader(client).ReadString('\n')
+	if reply != "APPROVED 125\n" {
+		t.Errorf("expected \"APPROVED 125\\n\", received %q", reply)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("received error (%v)", err)
+	}
+}
+
+// should fail when the expected bytes never arrive
+func TestTCPScriptRunTimeout(t *testing.T) {
+	script, _ := TCPScriptFromJSON([]byte(`{
+		"tcp": {
+			"port": "9100",
+			"timeout": "10ms",
+			"steps": [{ "expect": "HELLO" }]
+		}
+	}`))
+
+	client, server := net.Pipe()
+	defer client.Close()
+
+	if err := script.Run(server); err == nil {
+		t.Error("error should not be nil")
+	}
+}
+
+// should expect bytes that aren't valid UTF-8
+func TestTCPScriptRunBinary(t *testing.T) {
+	script, err := TCPScriptFromJSON([]byte(`{
+		"tcp": {
+			"port": "9100",
+			"timeout": "1s",
+			"steps": [
+				{ "expectHex": "02ff9c" },
+				{ "sendHex": "06" }
+			]
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	client, server := net.Pipe()
+	done := make(chan error)
+	go func() { done <- script.Run(server) }()
+
+	client.Write([]byte{0x01, 0x02, 0xff, 0x9c})
+	reply := make([]byte, 1)
+	if _, err := client.Read(reply); err != nil || reply[0] != 0x06 {
+		t.Errorf("expected an ACK, received %x (%v)", reply, err)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("received error (%v)", err)
+	}
+}
@@ -0,0 +1,212 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is what response templates are rendered with: the captures
+// made while matching the request, and the request itself.  JSON is the body
+// decoded as JSON, or nil if it isn't.
+type TemplateData struct {
+	Vars    map[string]string
+	Method  string
+	Path    string
+	Query   url.Values
+	Headers http.Header
+	Body    string
+	JSON    any
+}
+
+// textFuncs transform values in templates.
+var textFuncs = map[string]any{
+	"default": func(fallback any, v any) any {
+		if v == nil || v == "" {
+			return fallback
+		}
+		return v
+	},
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"trim":    strings.TrimSpace,
+	"replace": strings.ReplaceAll,
+	"split":   strings.Split,
+	"join": func(sep string, items []string) string {
+		return strings.Join(items, sep)
+	},
+}
+
+// templateFuncs are the helpers available to every template.
+var templateFuncs = funcs(textFuncs, helperFuncs)
+
+func funcs(sets ...map[string]any) template.FuncMap {
+	all := make(template.FuncMap)
+	for _, set := range sets {
+		for name, f := range set {
+			all[name] = f
+		}
+	}
+	return all
+}
+
+var legacyVariable = regexp.MustCompile(`{{(\$?)([[:alnum:]_]+)}}`)
+
+// templateKeywords can appear alone in an action, so aren't captures.
+var templateKeywords = map[string]bool{
+	"end": true, "else": true, "break": true, "continue": true, "nil": true,
+	"true": true, "false": true,
+}
+
+// legacyVariables rewrites the "{{name}}" and "{{$name}}" captures scripts
+// used before templates into lookups of Vars, leaving keywords, helpers and
+// variables the template declares alone.
+func legacyVariables(text string) string {
+	return legacyVariable.ReplaceAllStringFunc(text, func(v string) string {
+		m := legacyVariable.FindStringSubmatch(v)
+		dollar, name := m[1], m[2]
+
+		if dollar == "" && (templateKeywords[name] || templateFuncs[name] != nil) {
+			return v
+		} else if dollar != "" && regexp.MustCompile(`\$`+name+
+			`\s*(:=|=|,)`).MatchString(text) {
+			return v
+		}
+		return fmt.Sprintf("{{index .Vars %q}}", name)
+	})
+}
+
+// compileTemplate parses a response template.
+func compileTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).
+		Option("missingkey=zero").Parse(legacyVariables(text))
+}
+
+// compileDocument replaces each string in a decoded JSON document that holds
+// a template with the parsed template, reporting whether there were any.
+// Rendering each string on its own, rather than the document as a whole, lets
+// renderDocument encode whatever a template produces as a JSON string.
+func compileDocument(doc any) (any, bool, error) {
+	var err error
+	found := false
+
+	switch node := doc.(type) {
+	case string:
+		if !strings.Contains(node, "{{") {
+			return node, false, nil
+		}
+		t, err := compileTemplate("body", node)
+		return t, true, err
+	case map[string]any:
+		compiled := make(map[string]any)
+		for k, v := range node {
+			var ok bool
+			if compiled[k], ok, err = compileDocument(v); err != nil {
+				return nil, false, err
+			}
+			found = found || ok
+		}
+		return compiled, found, nil
+	case []any:
+		compiled := make([]any, len(node))
+		for i, v := range node {
+			var ok bool
+			if compiled[i], ok, err = compileDocument(v); err != nil {
+				return nil, false, err
+			}
+			found = found || ok
+		}
+		return compiled, found, nil
+	default:
+		return doc, false, nil
+	}
+}
+
+// renderDocument renders the templates compileDocument left in a document,
+// and encodes the result as JSON.
+func renderDocument(doc any, data *TemplateData) ([]byte, error) {
+	var fill func(node any) (any, error)
+	fill = func(node any) (any, error) {
+		var err error
+
+		switch v := node.(type) {
+		case *template.Template:
+			s, err := render(v, data)
+			return string(s), err
+		case map[string]any:
+			filled := make(map[string]any)
+			for k, child := range v {
+				if filled[k], err = fill(child); err != nil {
+					return nil, err
+				}
+			}
+			return filled, nil
+		case []any:
+			filled := make([]any, len(v))
+			for i, child := range v {
+				if filled[i], err = fill(child); err != nil {
+					return nil, err
+				}
+			}
+			return filled, nil
+		default:
+			return node, nil
+		}
+	}
+
+	filled, err := fill(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(filled)
+}
+
+// templateData gathers what a response is rendered with.
+func templateData(req *http.Request, vars map[string]string) *TemplateData {
+	data := &TemplateData{
+		Vars:    vars,
+		Method:  req.Method,
+		Path:    req.URL.Path,
+		Query:   req.URL.Query(),
+		Headers: req.Header,
+	}
+	if data.Vars == nil {
+		data.Vars = make(map[string]string)
+	}
+
+	if body, err := peekBody(req); err == nil {
+		data.Body = string(body)
+		if json.Unmarshal(body, &data.JSON) != nil {
+			data.JSON = nil
+		}
+	}
+
+	return data
+}
+
+func render(t *template.Template, data *TemplateData) ([]byte, error) {
+	var b bytes.Buffer
+	err := t.Execute(&b, data)
+	return b.Bytes(), err
+}
+
+// peekBody reads a request's body, leaving it in place to be read again.
+func peekBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, err
+}
@@ -0,0 +1,109 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func templateScript(t *testing.T, response string) *HTTPAction {
+	return parseAction(t, `{
+		"request": { "method": "post", "url": "/items" },
+		"response": `+response+`
+	}`)
+}
+
+func renderAction(action *HTTPAction, body string,
+	vars map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/items?page=2&tag=a&tag=b",
+		strings.NewReader(body))
+	req.Header.Set("X-Trace", "t-1")
+
+	w := httptest.NewRecorder()
+	action.Write(w, req, vars)
+	return w
+}
+
+// should still substitute captures written the old way
+func TestTemplateLegacyVariables(t *testing.T) {
+	result := templateScript(t, `{
+		"status": 200,
+		"headers": { "x-id": "{{id}}" },
+		"body": { "id": "{{id}}", "other": "{{$other}}", "missing": "{{none}}" }
+	}`)
+
+	w := renderAction(result, "", map[string]string{"id": "1", "other": "2"})
+	if w.Header().Get("x-id") != "1" {
+		t.Errorf("expected header \"1\", received %q", w.Header().Get("x-id"))
+	}
+	if body := w.Body.String(); body != `{"id":"1","missing":"","other":"2"}` {
+		t.Errorf("unexpected body %s", body)
+	}
+}
+
+// should render request fields, conditionals, loops and helpers
+func TestTemplateRequestFields(t *testing.T) {
+	result := templateScript(t, `{
+		"status": 201,
+		"headers": { "x-trace": "{{.Headers.Get \"X-Trace\" | upper}}" },
+		"body": {
+			"method": "{{.Method}}",
+			"path": "{{.Path}}",
+			"page": "{{.Query.Get \"page\"}}",
+			"tags": "{{range $i, $t := .Query.tag}}{{if $i}},{{end}}{{$t}}{{end}}",
+			"name": "{{.JSON.user.name}}",
+			"nick": "{{.JSON.user.nick | default \"none\"}}",
+			"user": "{{json .JSON.user | len}}"
+		}
+	}`)
+
+	w := renderAction(result, `{"user": {"name": "ann"}}`, nil)
+	if w.Code != 201 || w.Header().Get("x-trace") != "T-1" {
+		t.Errorf("unexpected response %d %v", w.Code, w.Header())
+	}
+
+	expected := `{"method":"POST","name":"ann","nick":"none","page":"2",` +
+		`"path":"/items","tags":"a,b","user":"14"}`
+	if body := w.Body.String(); body != expected {
+		t.Errorf("expected %s, received %s", expected, body)
+	}
+}
+
+// should return an error for a template that doesn't parse
+func TestTemplateError(t *testing.T) {
+	_, err := HTTPActionFromJSON([]byte(`{
+		"request": { "method": "get" },
+		"response": { "status": 200, "body": "{{if .Method}}" }
+	}`))
+
+	if err == nil {
+		t.Error("error should not be nil")
+	}
+}
+
+// should keep whatever a template renders inside its JSON string
+func TestTemplateJSONEscapes(t *testing.T) {
+	result := templateScript(t, `{
+		"status": 200,
+		"body": {
+			"name": "{{.JSON.name}}",
+			"raw": "{{.Body}}",
+			"list": ["{{.Vars.id}}", 1, true]
+		}
+	}`)
+
+	body := `{"name": "bob \"x\"\n\\"}`
+	w := renderAction(result, body, map[string]string{"id": `"`})
+
+	var rendered map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &rendered); err != nil {
+		t.Fatalf("expected valid JSON, received %s", w.Body.String())
+	}
+	expected := map[string]any{"name": "bob \"x\"\n\\", "raw": body,
+		"list": []any{`"`, 1.0, true}}
+	if !reflect.DeepEqual(rendered, expected) {
+		t.Errorf("expected %v, received %v", expected, rendered)
+	}
+}
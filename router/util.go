@@ -3,15 +3,12 @@ package router
 import (
 	"fmt"
 	"regexp"
+	"sort"
 )
 
-func match(re *regexp.Regexp, target string) (bool, map[string]string) {
-	matches := re.FindStringSubmatch(target)
-
-	if len(matches) == 0 {
-		return false, nil
-	}
-
+// captures names the submatches of a successful match, using the group's name
+// where it has one and its index otherwise.
+func captures(re *regexp.Regexp, matches []string) map[string]string {
 	groups := make(map[string]string)
 	names := re.SubexpNames()
 
@@ -24,5 +21,36 @@ func match(re *regexp.Regexp, target string) (bool, map[string]string) {
 		}
 	}
 
-	return true, groups
+	return groups
+}
+
+func match(re *regexp.Regexp, target string) (bool, map[string]string) {
+	matches := re.FindStringSubmatch(target)
+
+	if len(matches) == 0 {
+		return false, nil
+	}
+
+	return true, captures(re, matches)
+}
+
+// compile compiles a regular expression from a script so that it must match
+// the whole of a string, unless partial is set, in which case matching any
+// part of the string is enough.
+func compile(pattern string, partial bool) (*regexp.Regexp, error) {
+	if _, err := regexp.Compile(pattern); err != nil || partial {
+		return regexp.Compile(pattern)
+	}
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+// sortedKeys gives a map's keys in order, so that scripts built from maps
+// behave the same way every time they're loaded.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
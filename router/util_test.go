@@ -1,7 +1,10 @@
 package router
 
 import (
+	"net/http"
+	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -50,3 +53,109 @@ func TestRouterUtilMatchFails(t *testing.T) {
 		t.Error("expected no groups")
 	}
 }
+
+// should match whole strings unless partial matching is asked for
+func TestRouterUtilCompile(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		partial  bool
+		target   string
+		expected bool
+	}{
+		{"get", false, "get", true},
+		{"get", false, "forget", false},
+		{"users", false, "superusers", false},
+		{"users", true, "superusers", true},
+		{`\d+`, false, "123", true},
+		{`\d+`, false, "123abc", false},
+		{`\d+`, true, "123abc", true},
+		{"a|ab", false, "ab", true},
+		{"a|b", false, "ab", false},
+		{"", false, "", true},
+		{"", false, "a", false},
+		{"", true, "a", true},
+	}
+
+	for _, test := range tests {
+		re, err := compile(test.pattern, test.partial)
+		if err != nil {
+			t.Fatalf("received error (%v)", err)
+		}
+
+		if matched, _ := match(re, test.target); matched != test.expected {
+			t.Errorf("expected %q (partial %v) against %q to be %v",
+				test.pattern, test.partial, test.target, test.expected)
+		}
+	}
+}
+
+// should return an error rather than anchoring an invalid pattern
+func TestRouterUtilCompileError(t *testing.T) {
+	if _, err := compile("a)|(b", false); err == nil {
+		t.Error("error should not be nil")
+	}
+}
+
+// should anchor route segments, header values and query values in scripts
+func TestRouterUtilAnchoredScripts(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  string
+		target   string
+		expected bool
+	}{
+		{"segment", `"path": "/users"`, "/users", true},
+		{"segment", `"path": "/users"`, "/superusers", false},
+		{"partial segment", `"path": "/users", "partialPath": true`,
+			"/superusers", true},
+		{"header", `"headers": { "x-a": "json" }`, "/?h=json", true},
+		{"header", `"headers": { "x-a": "json" }`, "/?h=application/json",
+			false},
+		{"partial header",
+			`"headers": { "x-a": { "value": "json", "partial": true } }`,
+			"/?h=application/json", true},
+		{"query", `"query": { "q": { "regex": "\\d+" } }`, "/?q=12", true},
+		{"query", `"query": { "q": { "regex": "\\d+" } }`, "/?q=12a", false},
+		{"partial query",
+			`"query": { "q": { "regex": "\\d+", "partial": true } }`,
+			"/?q=12a", true},
+	}
+
+	for _, test := range tests {
+		action, err := HTTPActionFromJSON([]byte(`{
+			"request": { "method": "get", ` + test.request + ` }
+		}`))
+		if err != nil {
+			t.Fatalf("%s: received error (%v)", test.name, err)
+		}
+
+		var tree Path
+		tree.Add(action.Request.Path).AddAction(action)
+
+		u, _ := url.Parse(test.target)
+		req := &http.Request{Method: "GET", URL: u, Header: http.Header{}}
+		if h := u.Query().Get("h"); h != "" {
+			req.Header.Set("x-a", h)
+			u.RawQuery = ""
+		}
+
+		path := append([]string{"get"}, strings.Split(u.Path, "/")[1:]...)
+		if path[len(path)-1] == "" {
+			path = path[:len(path)-1]
+		}
+
+		matched := false
+		for _, route := range tree.Resolve(path) {
+			for _, a := range route.Node.Actions {
+				if ok, _ := a.Match(req, nil); ok {
+					matched = true
+				}
+			}
+		}
+
+		if matched != test.expected {
+			t.Errorf("%s: expected %s against %q to be %v", test.name,
+				test.request, test.target, test.expected)
+		}
+	}
+}
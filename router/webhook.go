@@ -0,0 +1,170 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// WebhookClient sends every webhook; replace it to change timeouts or
+// transport settings.
+var WebhookClient = &http.Client{Timeout: 30 * time.Second}
+
+// Webhook is a request sent after a mocked response.  Its URL, headers and
+// body are templates, rendered with the same data as the response.  A body
+// given as a string is sent as text, and anything else as JSON.
+type Webhook struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    []byte
+	Delay   time.Duration
+	// the templates the URL, Headers and Body are rendered from.
+	url      *template.Template
+	headers  map[string]*template.Template
+	body     *template.Template
+	document any
+}
+
+type webhookJSON struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    any               `json:"body,omitempty"`
+	Delay   string            `json:"delay,omitempty"`
+}
+
+func after(action *HTTPAction, parsed *httpJSON) error {
+	for _, h := range parsed.After {
+		if h.URL == "" {
+			return errors.New("webhook url is required")
+		}
+
+		hook := Webhook{
+			Method:  strings.ToUpper(h.Method),
+			URL:     h.URL,
+			Headers: h.Headers,
+		}
+
+		if hook.Method == "" {
+			hook.Method = http.MethodPost
+		}
+
+		if err := hook.compile(h.Body); err != nil {
+			return err
+		}
+
+		if h.Delay != "" {
+			var err error
+			if hook.Delay, err = time.ParseDuration(h.Delay); err != nil {
+				return err
+			}
+		}
+
+		action.After = append(action.After, hook)
+	}
+
+	return nil
+}
+
+// compile parses the hook's URL, headers and body as templates, once and for
+// all.
+func (h *Webhook) compile(body any) error {
+	var err error
+	if h.url, err = compileTemplate("url", h.URL); err != nil {
+		return fmt.Errorf("webhook url: %w", err)
+	}
+
+	h.headers = make(map[string]*template.Template)
+	for k, v := range h.Headers {
+		if h.headers[k], err = compileTemplate(k, v); err != nil {
+			return fmt.Errorf("webhook header %q: %w", k, err)
+		}
+	}
+
+	switch b := body.(type) {
+	case nil:
+	case string:
+		h.Body = []byte(b)
+		h.body, err = compileTemplate("body", b)
+	default:
+		h.Body, _ = json.Marshal(b)
+		h.document, _, err = compileDocument(b)
+	}
+	if err != nil {
+		return fmt.Errorf("webhook body: %w", err)
+	}
+	return nil
+}
+
+// Send waits out the hook's delay, then makes its request with its templates
+// rendered from data.
+func (h *Webhook) Send(data *TemplateData) error {
+	var body io.Reader
+	switch {
+	case h.document != nil:
+		b, err := renderDocument(h.document, data)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	case h.body != nil:
+		b, err := render(h.body, data)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	time.Sleep(h.Delay)
+
+	url, err := render(h.url, data)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(h.Method, string(url), body)
+	if err != nil {
+		return err
+	}
+	if h.document != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, k := range sortedKeys(h.headers) {
+		v, err := render(h.headers[k], data)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(k, string(v))
+	}
+
+	res, err := WebhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, res.Body)
+	return res.Body.Close()
+}
+
+// Trigger sends the action's webhooks in the background, one after another,
+// rendered from the request and the captures made matching it.
+func (a *HTTPAction) Trigger(req *http.Request, vars map[string]string) {
+	if len(a.After) == 0 {
+		return
+	}
+
+	data := templateData(req, vars)
+	go func() {
+		for _, h := range a.After {
+			if err := h.Send(data); err != nil {
+				log.Printf("mocket: error sending webhook to %s (%v)\n", h.URL, err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,118 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// should correctly parse webhooks
+func TestHTTPActionAfter(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "post"
+		},
+		"after": [{
+			"url": "http://localhost/hook",
+			"delay": "2s",
+			"body": { "id": "{{$id}}" }
+		}]
+	}`))
+
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	if len(result.After) != 1 {
+		t.Fatalf("expected 1 webhook, got %d", len(result.After))
+	}
+
+	hook := result.After[0]
+	if hook.Method != "POST" {
+		t.Errorf("expected method to default to \"POST\", was %q", hook.Method)
+	}
+	if hook.Delay != 2*time.Second {
+		t.Errorf("expected a delay of 2s, got %v", hook.Delay)
+	}
+	if string(hook.Body) != `{"id":"{{$id}}"}` {
+		t.Errorf("expected body to be %q, received %q", `{"id":"{{$id}}"}`,
+			hook.Body)
+	}
+}
+
+// should return an error for a malformed delay
+func TestHTTPActionAfterDelayError(t *testing.T) {
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "post"
+		},
+		"after": [{ "url": "http://localhost/hook", "delay": "soon" }]
+	}`))
+
+	if err == nil {
+		t.Error("error should not be nil")
+	}
+
+	if result != nil {
+		t.Error("expected result to be nil")
+	}
+}
+
+// should render the webhook request's templates, escaping JSON bodies
+func TestWebhookSend(t *testing.T) {
+	type received struct {
+		method, uri, header, contentType, body string
+	}
+	hooks := make(chan received, 2)
+
+	callback := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			hooks <- received{req.Method, req.URL.RequestURI(),
+				req.Header.Get("x-order"), req.Header.Get("content-type"),
+				string(body)}
+		}))
+	defer callback.Close()
+
+	result, err := HTTPActionFromJSON([]byte(`{
+		"request": { "method": "post" },
+		"after": [
+			{
+				"method": "put",
+				"url": "` + callback.URL + `/orders/{{$order_id}}",
+				"headers": { "x-order": "{{order_id}}" },
+				"body": { "id": "{{$order_id}}", "note": "{{.JSON.note}}" }
+			},
+			{
+				"url": "` + callback.URL + `/events?kind={{.Method | lower}}",
+				"headers": { "content-type": "text/plain" },
+				"body": "order {{.Vars.order_id}} placed"
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	req := httptest.NewRequest("POST", "/orders",
+		strings.NewReader(`{"note": "say \"hi\""}`))
+	data := templateData(req, map[string]string{"order_id": "42"})
+
+	expected := []received{
+		{"PUT", "/orders/42", "42", "application/json",
+			`{"id":"42","note":"say \"hi\""}`},
+		{"POST", "/events?kind=post", "", "text/plain", "order 42 placed"},
+	}
+	for i, hook := range result.After {
+		if err := hook.Send(data); err != nil {
+			t.Fatalf("received error (%v)", err)
+		}
+
+		if r := <-hooks; r != expected[i] {
+			t.Errorf("expected %+v, received %+v", expected[i], r)
+		}
+	}
+}
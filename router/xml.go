@@ -0,0 +1,148 @@
+package router
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// XMLMatcher compares a request body as an XML document: every one of
+// Predicates must hold.
+type XMLMatcher struct {
+	Predicates []XMLPredicate
+}
+
+// XMLPredicate requires that Path selects a node whose text matches Value, or
+// just that it selects something when Value is nil.  The first matching text
+// is captured as As, if it's set.
+type XMLPredicate struct {
+	Path  XPath
+	Value *regexp.Regexp
+	As    string
+}
+
+type xpathPredicateJSON struct {
+	Path    string  `json:"path"`
+	Value   *string `json:"value,omitempty"`
+	Regex   *string `json:"regex,omitempty"`
+	Partial bool    `json:"partial,omitempty"`
+	As      string  `json:"as,omitempty"`
+}
+
+func xmlPredicate(spec *xpathPredicateJSON,
+	namespaces map[string]string) (XMLPredicate, error) {
+	var err error
+	p := XMLPredicate{As: spec.As}
+
+	if p.Path, err = ParseXPath(spec.Path, namespaces); err != nil {
+		return p, err
+	}
+
+	switch {
+	case spec.Value != nil && spec.Regex != nil:
+		err = fmt.Errorf("xpath %q has both a value and a regex", spec.Path)
+	case spec.Regex != nil:
+		p.Value, err = compile(*spec.Regex, spec.Partial)
+	case spec.Value != nil:
+		p.Value, err = compilePattern(*spec.Value, nil, false)
+	}
+
+	return p, err
+}
+
+func requestXML(action *HTTPAction, parsed *httpJSON) error {
+	var err error
+	if parsed.Request.SOAPAction != nil {
+		action.Request.SOAPAction, err = compilePattern(
+			*parsed.Request.SOAPAction, nil, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(parsed.Request.XPath) == 0 {
+		return nil
+	}
+
+	m := new(XMLMatcher)
+	for i := range parsed.Request.XPath {
+		spec := &parsed.Request.XPath[i]
+		if p, err := xmlPredicate(spec, parsed.Request.Namespaces); err != nil {
+			return err
+		} else {
+			m.Predicates = append(m.Predicates, p)
+		}
+	}
+
+	action.Request.XML = m
+	return nil
+}
+
+// Match parses body and compares it against the matcher, returning the
+// captures made along the way.  Text is compared without its surrounding
+// whitespace.
+func (m *XMLMatcher) Match(body []byte) (bool, map[string]string) {
+	doc, err := parseXML(body)
+	if err != nil {
+		return false, nil
+	}
+
+	vars := make(map[string]string)
+	for _, p := range m.Predicates {
+		matched := false
+		for _, n := range p.Path.selectFrom(doc) {
+			groups := make(map[string]string)
+			if p.Value == nil {
+				matched = true
+			} else {
+				matched, groups = match(p.Value, n.value())
+			}
+
+			if matched {
+				if p.As != "" {
+					groups[p.As] = n.value()
+				}
+				merge(vars, groups)
+				break
+			}
+		}
+
+		if !matched {
+			return false, nil
+		}
+	}
+
+	return true, vars
+}
+
+// soapAction gives the action a SOAP request names: the SOAPAction header in
+// SOAP 1.1, or the action parameter of the content type in SOAP 1.2.
+func soapAction(req *http.Request) (string, bool) {
+	if values := req.Header.Values("SOAPAction"); len(values) > 0 {
+		return strings.Trim(values[0], `"`), true
+	}
+
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if action, ok := params["action"]; err == nil && ok {
+		return action, true
+	}
+
+	return "", false
+}
+
+// CompareSOAPAction matches the action a SOAP request names against the
+// script's, if it has one.
+func (a *HTTPAction) CompareSOAPAction(req *http.Request) (bool,
+	map[string]string) {
+	if a.Request.SOAPAction == nil {
+		return true, nil
+	}
+
+	action, ok := soapAction(req)
+	if !ok {
+		return false, nil
+	}
+	return match(a.Request.SOAPAction, action)
+}
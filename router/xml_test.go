@@ -0,0 +1,87 @@
+package router
+
+import (
+	"net/http"
+	"testing"
+)
+
+func soapScript(t *testing.T) *HTTPAction {
+	return requestAction(t, "post", `"soapAction": "urn:users/{{/Get(?<op>\\w+)/}}",
+		"namespaces": { "s": "urn:envelope", "u": "urn:users" },
+		"xpath": [
+			{ "path": "//u:RequestId", "as": "requestId" },
+			{ "path": "/s:Envelope/s:Body/*/u:Id", "value": "{{/(?<id>\\d+)/}}" }
+		]`)
+}
+
+// should match xpath predicates and capture the text they select
+func TestCompareBodyXML(t *testing.T) {
+	result := soapScript(t)
+
+	matched, vars := result.CompareBody(xpathDocument)
+	if !matched {
+		t.Fatal("expected body to match")
+	}
+	if vars["requestId"] != "r-1" || vars["id"] != "42" {
+		t.Errorf("unexpected captures %v", vars)
+	}
+
+	for _, body := range []string{
+		`<s:Envelope xmlns:s="urn:envelope" xmlns:u="urn:users">
+			<s:Body><u:GetUser><u:Id>42</u:Id></u:GetUser></s:Body>
+		</s:Envelope>`,
+		`<s:Envelope xmlns:s="urn:envelope" xmlns:u="urn:other">
+			<s:Header><u:RequestId>r-1</u:RequestId></s:Header>
+			<s:Body><u:GetUser><u:Id>42</u:Id></u:GetUser></s:Body>
+		</s:Envelope>`,
+		`<s:Envelope xmlns:s="urn:envelope">`,
+		`{"id": 42}`,
+	} {
+		if matched, _ := result.CompareBody(body); matched {
+			t.Errorf("expected %s not to match", body)
+		}
+	}
+}
+
+// should match the SOAP action from the header or the content type
+func TestCompareSOAPAction(t *testing.T) {
+	result := soapScript(t)
+
+	tests := []struct {
+		header   http.Header
+		expected bool
+	}{
+		{http.Header{"Soapaction": {`"urn:users/GetUser"`}}, true},
+		{http.Header{"Content-Type": {
+			`application/soap+xml; charset=utf-8; action="urn:users/GetUser"`,
+		}}, true},
+		{http.Header{"Soapaction": {`"urn:users/DeleteUser"`}}, false},
+		{http.Header{"Content-Type": {"text/xml"}}, false},
+	}
+
+	for _, test := range tests {
+		req := &http.Request{Header: test.header}
+		matched, vars := result.CompareSOAPAction(req)
+		if matched != test.expected {
+			t.Errorf("expected %v for %v, got %v", test.expected, test.header,
+				matched)
+		}
+		if matched && vars["op"] != "User" {
+			t.Errorf("expected \"op\" to be \"User\", was %q", vars["op"])
+		}
+	}
+}
+
+// should return an error for an xpath with an undeclared prefix
+func TestHTTPActionRequestXPathError(t *testing.T) {
+	_, err := HTTPActionFromJSON([]byte(`{
+		"request": {
+			"method": "post",
+			"xpath": [{ "path": "/u:Id" }]
+		}
+	}`))
+
+	if err == nil {
+		t.Error("error should not be nil")
+	}
+}
@@ -0,0 +1,321 @@
+package router
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XPath selects nodes from an XML document.  It supports the common subset of
+// XPath used to pick values out of SOAP envelopes: absolute paths of child
+// ("/") and descendant ("//") steps, names with namespace prefixes, "*",
+// attributes by "@name", "text()", and predicates giving a position ([n]), a
+// path that must exist ([name]) or a path with a value ([@name='value']).
+//
+// Prefixes are resolved against the namespaces given when the path is parsed,
+// and an unprefixed name matches its local name in any namespace.
+type XPath []xpathStep
+
+const (
+	xpathElement = iota
+	xpathAttribute
+	xpathText
+)
+
+type xpathStep struct {
+	descendant bool
+	kind       int
+	space      string
+	local      string
+	anySpace   bool
+	predicates []xpathPredicate
+}
+
+type xpathPredicate struct {
+	index int
+	path  XPath
+	value *string
+}
+
+// xmlNode is an element of a parsed document, or an attribute or text node
+// selected from one, which holds just its text.
+type xmlNode struct {
+	name     xml.Name
+	attr     []xml.Attr
+	children []*xmlNode
+	// own is the node's direct text, and text includes its descendants'.
+	own  string
+	text string
+}
+
+func parseXML(body []byte) (*xmlNode, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	root := new(xmlNode)
+	stack := []*xmlNode{root}
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name, attr: t.Attr}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			stack[len(stack)-1].own += string(t)
+			for _, n := range stack {
+				n.text += string(t)
+			}
+		}
+	}
+
+	if len(root.children) != 1 {
+		return nil, errors.New("xml document has no root element")
+	}
+	return root, nil
+}
+
+// value gives a node's text without its surrounding whitespace.
+func (n *xmlNode) value() string {
+	return strings.TrimSpace(n.text)
+}
+
+func (n *xmlNode) descendants() []*xmlNode {
+	nodes := []*xmlNode{n}
+	for _, child := range n.children {
+		nodes = append(nodes, child.descendants()...)
+	}
+	return nodes
+}
+
+// xpathEnd finds the end of the step or predicate at the start of s: the
+// first stop character outside brackets and quotes.
+func xpathEnd(s string, stop byte) int {
+	depth := 0
+	var quote byte
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == stop && depth == 0:
+			return i
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+
+	return len(s)
+}
+
+func ParseXPath(path string, namespaces map[string]string) (XPath, error) {
+	s := strings.TrimSpace(path)
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("xpath %q must start with /", path)
+	}
+	return parseXPathSteps(path, s, namespaces)
+}
+
+func parseXPathSteps(path string, s string,
+	namespaces map[string]string) (XPath, error) {
+	var steps XPath
+
+	for first := true; s != ""; first = false {
+		var step xpathStep
+
+		if strings.HasPrefix(s, "//") {
+			step.descendant = true
+			s = s[2:]
+		} else if strings.HasPrefix(s, "/") {
+			s = s[1:]
+		} else if !first {
+			return nil, fmt.Errorf("xpath %q is malformed at %q", path, s)
+		}
+
+		end := xpathEnd(s, '/')
+		if err := step.parse(path, s[:end], namespaces); err != nil {
+			return nil, err
+		}
+		s = s[end:]
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+func (step *xpathStep) parse(path string, s string,
+	namespaces map[string]string) error {
+	name := s
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		name, s = s[:i], s[i:]
+	} else {
+		s = ""
+	}
+
+	switch {
+	case name == "text()":
+		step.kind = xpathText
+	case strings.HasPrefix(name, "@"):
+		step.kind = xpathAttribute
+		name = name[1:]
+		fallthrough
+	default:
+		prefix, local, found := strings.Cut(name, ":")
+		if !found {
+			step.local, step.anySpace = prefix, true
+		} else if space, ok := namespaces[prefix]; !ok {
+			return fmt.Errorf("xpath %q has an undeclared prefix %q", path,
+				prefix)
+		} else {
+			step.space, step.local = space, local
+		}
+
+		if step.local == "" {
+			return fmt.Errorf("xpath %q has an empty step", path)
+		}
+	}
+
+	for s != "" {
+		end := xpathEnd(s[1:], ']') + 1
+		if !strings.HasPrefix(s, "[") || end >= len(s) {
+			return fmt.Errorf("xpath %q has a malformed predicate", path)
+		}
+
+		p, err := parsePredicate(path, strings.TrimSpace(s[1:end]), namespaces)
+		if err != nil {
+			return err
+		}
+		step.predicates = append(step.predicates, p)
+		s = s[end+1:]
+	}
+
+	return nil
+}
+
+func parsePredicate(path string, s string,
+	namespaces map[string]string) (xpathPredicate, error) {
+	var err error
+	var p xpathPredicate
+
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 {
+			return p, fmt.Errorf("xpath %q has a position below 1", path)
+		}
+		p.index = n
+		return p, nil
+	}
+
+	if i := xpathEnd(s, '='); i < len(s) {
+		literal := strings.TrimSpace(s[i+1:])
+		if len(literal) < 2 || literal[0] != literal[len(literal)-1] ||
+			literal[0] != '\'' && literal[0] != '"' {
+			return p, fmt.Errorf("xpath %q compares with a malformed string",
+				path)
+		}
+
+		literal = literal[1 : len(literal)-1]
+		p.value = &literal
+		s = strings.TrimSpace(s[:i])
+	}
+
+	if s == "" {
+		return p, fmt.Errorf("xpath %q has an empty predicate", path)
+	}
+	p.path, err = parseXPathSteps(path, s, namespaces)
+	return p, err
+}
+
+func (step *xpathStep) matches(name xml.Name) bool {
+	return (step.local == "*" || name.Local == step.local) &&
+		(step.anySpace || name.Space == step.space)
+}
+
+// holds checks a predicate against one of the nodes a step selected, at the
+// given position (counting from 1).
+func (p *xpathPredicate) holds(n *xmlNode, position int) bool {
+	if p.index != 0 {
+		return position == p.index
+	}
+
+	for _, selected := range p.path.selectFrom(n) {
+		if p.value == nil || selected.value() == *p.value {
+			return true
+		}
+	}
+	return false
+}
+
+// children gives the nodes a step selects directly beneath n.
+func (step *xpathStep) children(n *xmlNode) []*xmlNode {
+	var nodes []*xmlNode
+
+	switch step.kind {
+	case xpathElement:
+		for _, child := range n.children {
+			if step.matches(child.name) {
+				nodes = append(nodes, child)
+			}
+		}
+	case xpathAttribute:
+		for _, a := range n.attr {
+			if a.Name.Space != "xmlns" && a.Name.Local != "xmlns" &&
+				step.matches(a.Name) {
+				nodes = append(nodes, &xmlNode{own: a.Value, text: a.Value})
+			}
+		}
+	case xpathText:
+		if n.name.Local != "" && n.own != "" {
+			nodes = append(nodes, &xmlNode{own: n.own, text: n.own})
+		}
+	}
+
+	for _, p := range step.predicates {
+		var filtered []*xmlNode
+		for i, node := range nodes {
+			if p.holds(node, i+1) {
+				filtered = append(filtered, node)
+			}
+		}
+		nodes = filtered
+	}
+
+	return nodes
+}
+
+func (p XPath) selectFrom(n *xmlNode) []*xmlNode {
+	nodes := []*xmlNode{n}
+
+	for _, step := range p {
+		var next []*xmlNode
+		for _, node := range nodes {
+			contexts := []*xmlNode{node}
+			if step.descendant {
+				contexts = node.descendants()
+			}
+			for _, c := range contexts {
+				next = append(next, step.children(c)...)
+			}
+		}
+		nodes = next
+	}
+
+	return nodes
+}
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"reflect"
+	"testing"
+)
+
+const xpathDocument = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="urn:envelope" xmlns:u="urn:users">
+	<s:Header><u:RequestId>r-1</u:RequestId></s:Header>
+	<s:Body>
+		<u:GetUser mode="full">
+			<u:Id>42</u:Id>
+			<u:Tag kind="a">one</u:Tag>
+			<u:Tag kind="b">two</u:Tag>
+			<Id>other</Id>
+		</u:GetUser>
+	</s:Body>
+</s:Envelope>`
+
+// should select nodes by namespaced name, descent, attribute and predicate
+func TestXPathSelect(t *testing.T) {
+	doc, err := parseXML([]byte(xpathDocument))
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+	namespaces := map[string]string{"s": "urn:envelope", "u": "urn:users",
+		"x": "urn:other"}
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{"/s:Envelope/s:Body/u:GetUser/u:Id", []string{"42"}},
+		{"/s:Envelope/s:Body/u:GetUser/x:Id", nil},
+		{"//u:RequestId", []string{"r-1"}},
+		{"//Id", []string{"42", "other"}},
+		{"//u:GetUser/@mode", []string{"full"}},
+		{"//u:Tag[2]", []string{"two"}},
+		{"//u:Tag[@kind='a']/text()", []string{"one"}},
+		{"//u:GetUser[u:Id=\"42\"]/u:Tag[1]", []string{"one"}},
+		{"//u:GetUser[u:Id='7']", nil},
+		{"/s:Envelope/*/u:*/u:Id", []string{"42"}},
+	}
+
+	for _, test := range tests {
+		path, err := ParseXPath(test.path, namespaces)
+		if err != nil {
+			t.Errorf("received error for %q (%v)", test.path, err)
+			continue
+		}
+
+		var values []string
+		for _, n := range path.selectFrom(doc) {
+			values = append(values, n.value())
+		}
+		if !reflect.DeepEqual(values, test.expected) {
+			t.Errorf("expected %v for %q, received %v", test.expected, test.path,
+				values)
+		}
+	}
+}
+
+// should return an error for a malformed path or an undeclared prefix
+func TestParseXPathError(t *testing.T) {
+	for _, path := range []string{"a/b", "/a/", "/a[1", "/a[0]", "/a[b=c]",
+		"/u:a"} {
+		if _, err := ParseXPath(path, nil); err == nil {
+			t.Errorf("expected an error for %q", path)
+		}
+	}
+}
@@ -1,52 +1,274 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"github.com/infinadam/mocket/router"
 	"io"
+	"log"
+	"net"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 )
 
+// Mocked and Proxied are the values of the MockedHeader on each reply, telling
+// clients whether a script answered or the request was sent upstream.
+const (
+	MockedHeader = "X-Mocket"
+	Mocked       = "mocked"
+	Proxied      = "proxied"
+)
+
+// Server answers requests from the scripts it loaded.  The route tree is
+// swapped whole when scripts are reloaded, so requests in flight keep the tree
+// they started with.
 type Server struct {
+	path      atomic.Pointer[router.Path]
+	layout    *layout
+	fallback  *router.Passthrough
+	tcp       map[string]*router.TCPScript
+	scenarios *router.Scenarios
+}
+
+// scripts is everything loaded from a script directory.
+type scripts struct {
 	path router.Path
+	tcp  map[string]*router.TCPScript
+	// the names of the scenarios the scripts take part in.
+	scenarios []string
 }
 
-func actionFromEntry(dir string, e os.DirEntry) (*router.HTTPAction, error) {
-	if e.IsDir() {
-		return nil, nil
+// isTCP reports whether a script describes a TCP conversation rather than an
+// HTTP exchange.
+func isTCP(script []byte) bool {
+	var kind struct {
+		TCP json.RawMessage `json:"tcp"`
 	}
+	return json.Unmarshal(script, &kind) == nil && kind.TCP != nil
+}
 
-	if json, err := os.ReadFile(dir + "/" + e.Name()); err != nil {
-		return nil, err
-	} else if action, err := router.HTTPActionFromJSON(json); err != nil {
+// mock is one script read from a file, named for the errors it causes.
+type mock struct {
+	name   string
+	script []byte
+}
+
+// readFile reads the scripts in the file at rel, a path relative to the script
+// directory, merging each over the defaults of the folders it's in.
+func readFile(l *layout, rel string, d defaults) ([]mock, error) {
+	file, err := os.ReadFile(filepath.Join(l.dir, filepath.FromSlash(rel)))
+	if err != nil {
 		return nil, err
+	} else if file, err = router.ScriptToJSON(rel, file); err != nil {
+		return nil, fmt.Errorf("%s: %w", rel, err)
+	}
+
+	fileDefaults, scripts, list, err := splitScripts(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rel, err)
+	}
+	d = d.over(fileDefaults)
+
+	mocks := make([]mock, len(scripts))
+	for i, script := range scripts {
+		// scripts in a list are named by their index.
+		mocks[i].name = rel
+		if list {
+			mocks[i].name = fmt.Sprintf("%s[%d]", rel, i)
+		}
+
+		if mocks[i].script, err = d.apply(script); err != nil {
+			return nil, fmt.Errorf("%s: %w", mocks[i].name, err)
+		}
+	}
+
+	return mocks, nil
+}
+
+// bodyFiles finds the files, relative to the script directory, that the
+// scripts in the file at rel stream their bodies from.
+func bodyFiles(l *layout, rel string, mocks []mock) []string {
+	var files []string
+
+	for _, m := range mocks {
+		var script struct {
+			Response struct {
+				BodyFile string `json:"bodyFile"`
+			} `json:"response"`
+		}
+		if json.Unmarshal(m.script, &script) != nil ||
+			script.Response.BodyFile == "" {
+			continue
+		}
+
+		file := filepath.FromSlash(script.Response.BodyFile)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(l.dir, filepath.FromSlash(path.Dir(rel)), file)
+		}
+		if r, err := filepath.Rel(l.dir, file); err == nil {
+			files = append(files, filepath.ToSlash(r))
+		}
+	}
+
+	return files
+}
+
+// loadScript loads one script from the file at rel.
+func (s *scripts) loadScript(l *layout, rel string, name string,
+	script []byte) error {
+	if isTCP(script) {
+		if tcp, err := router.TCPScriptFromJSON(script); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		} else if _, ok := s.tcp[tcp.Port]; ok {
+			return fmt.Errorf("%s: port %s already has a script", name,
+				tcp.Port)
+		} else {
+			s.tcp[tcp.Port] = tcp
+		}
+	} else if action, err := router.HTTPActionFromJSON(script); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
 	} else {
-		return action, nil
+		if err := l.bodyFile(action, rel); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		l.scope(action, rel)
+		if action.Scenario != nil {
+			s.scenarios = append(s.scenarios, action.Scenario.Name)
+		}
+		node := s.path.Add(action.Request.Path)
+		if action.Request.Rest != "" {
+			node = node.AddCatchAll(action.Request.Rest)
+		}
+		node.AddAction(action)
 	}
+
+	return nil
 }
 
-func MakeServer(dir string) (*Server, error) {
-	var entries []os.DirEntry
-	var err error
+// loadScripts reads every script the layout finds, failing if any of them is
+// invalid.
+func loadScripts(l *layout) (*scripts, error) {
+	loaded := &scripts{tcp: make(map[string]*router.TCPScript)}
+	byFolder := make(map[string]defaults)
+
+	files, err := l.files()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rel := range files {
+		dir := path.Dir(rel)
+		if !isDefaults(rel) {
+			continue
+		} else if _, ok := byFolder[dir]; ok {
+			return nil, fmt.Errorf("%s: folder already has defaults", rel)
+		} else if byFolder[dir], err = readDefaults(l, rel); err != nil {
+			return nil, fmt.Errorf("%s: %w", rel, err)
+		}
+	}
+
+	// files that scripts send as bodies aren't scripts themselves, so each
+	// file is read before any is loaded.
+	mocks := make(map[string][]mock)
+	failed := make(map[string]error)
+	bodies := make(map[string]bool)
+	for _, rel := range files {
+		if isDefaults(rel) {
+			continue
+		}
+
+		d := folderDefaults(byFolder, path.Dir(rel))
+		if mocks[rel], err = readFile(l, rel, d); err != nil {
+			failed[rel] = err
+		}
+		for _, body := range bodyFiles(l, rel, mocks[rel]) {
+			bodies[body] = true
+		}
+	}
+
+	for _, rel := range files {
+		if isDefaults(rel) || bodies[rel] {
+			continue
+		} else if err := failed[rel]; err != nil {
+			return nil, err
+		}
+
+		for _, m := range mocks[rel] {
+			if err := loaded.loadScript(l, rel, m.name, m.script); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return loaded, nil
+}
+
+func MakeServer(config *Config) (*Server, error) {
 	server := new(Server)
 
-	if entries, err = os.ReadDir(dir); err != nil {
+	l, err := makeLayout(config)
+	if err != nil {
+		return nil, err
+	}
+	server.layout = l
+
+	loaded, err := loadScripts(l)
+	if err != nil {
 		return nil, err
 	}
+	server.path.Store(&loaded.path)
+	server.tcp = loaded.tcp
+	server.scenarios = router.NewScenarios()
+	server.scenarios.Declare(loaded.scenarios)
 
-	for _, e := range entries {
-		if action, err := actionFromEntry(dir, e); err != nil {
+	if config.FallbackUpstream != "" {
+		server.fallback, err = router.NewPassthrough(config.FallbackUpstream)
+		if err != nil {
 			return nil, err
-		} else {
-			child := server.path.Add(action.Request.Path)
-			child.Action = action
 		}
 	}
 
 	return server, nil
 }
 
+// ServeTCP listens on the port of every TCP script, and plays the script to
+// each connection it accepts.
+func (s *Server) ServeTCP() error {
+	for port, script := range s.tcp {
+		l, err := net.Listen("tcp", ":"+port)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("mocket: tcp script listening on (%s)...\n", port)
+		go acceptTCP(l, script)
+	}
+
+	return nil
+}
+
+func acceptTCP(l net.Listener, script *router.TCPScript) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			log.Printf("mocket: error accepting tcp (%v)\n", err)
+			return
+		}
+
+		go func() {
+			if err := script.Run(conn); err != nil {
+				log.Printf("mocket: tcp script on (%s) failed (%v)\n",
+					script.Port, err)
+			}
+		}()
+	}
+}
+
 func merge(a map[string]string, b map[string]string) map[string]string {
 	for k, v := range b {
 		a[k] = v
@@ -55,36 +277,56 @@ func merge(a map[string]string, b map[string]string) map[string]string {
 	return a
 }
 
-// TODO: clean me up!
+// unmatched answers a request that no script matched, either by sending it to
+// the fallback upstream or with a 404.
+func (s *Server) unmatched(w http.ResponseWriter, req *http.Request) {
+	if s.fallback == nil {
+		w.WriteHeader(404)
+		return
+	}
+
+	w.Header().Set(MockedHeader, Proxied)
+	s.fallback.Forward(w, req)
+}
+
 func (s *Server) HandleRequest(w http.ResponseWriter, req *http.Request) {
-	url := strings.Split(req.URL.Path, "/")
+	// a trailing slash doesn't add a segment, just as it doesn't to a script.
+	url := strings.Split(strings.TrimSuffix(req.URL.Path, "/"), "/")
 	if req.Method == "" {
 		url[0] = "get"
 	} else {
 		url[0] = strings.ToLower(req.Method)
 	}
 
-	node, groups := s.path.Find(url, nil)
-
-	if node == nil || node.Action == nil {
-		w.WriteHeader(404)
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		w.WriteHeader(400)
 		return
 	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
 
-	for l, v := range req.Header {
-		_, vars := node.Action.CompareHeaders(l, strings.Join(v, ","))
-		groups = merge(groups, vars)
+	for _, route := range s.path.Load().Resolve(url) {
+		for _, action := range route.Node.Actions {
+			// a script only answers if its scenario lets it.
+			if matched, vars := action.Match(req, body); matched &&
+				s.scenarios.Claim(action) {
+				s.respond(w, req, action, merge(route.Groups, vars))
+				return
+			}
+		}
 	}
 
-	if body, err := io.ReadAll(req.Body); err != nil {
-		w.WriteHeader(404)
-		return
-	} else if matched, vars := node.Action.CompareBody(string(body)); !matched {
-		w.WriteHeader(404)
-		return
+	s.unmatched(w, req)
+}
+
+func (s *Server) respond(w http.ResponseWriter, req *http.Request,
+	action *router.HTTPAction, vars map[string]string) {
+	if action.Passthrough != nil {
+		w.Header().Set(MockedHeader, Proxied)
+		action.Passthrough.Forward(w, req)
 	} else {
-		groups = merge(groups, vars)
+		w.Header().Set(MockedHeader, Mocked)
+		action.Write(w, req, vars)
+		action.Trigger(req, vars)
 	}
-
-	node.Action.Write(w, groups)
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeScripts lays out files, by path relative to a new script directory,
+// returning the directory.
+func writeScripts(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for rel, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatalf("received error (%v)", err)
+		} else if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatalf("received error (%v)", err)
+		}
+	}
+	return dir
+}
+
+func serve(server *Server, method string,
+	url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	server.HandleRequest(w, httptest.NewRequest(method, url, nil))
+	return w
+}
+
+// should send a JSON file named as a body rather than load it as a script
+func TestServerBodyFileBesideScript(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"stripe/charges/data.json": `{"id": "ch_1"}`,
+		"stripe/charges/get.json": `{
+			"request": { "method": "get", "url": "/charges" },
+			"response": { "status": 200, "bodyFile": "data.json" }
+		}`,
+	})
+
+	server, err := MakeServer(&Config{ScriptDir: dir})
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	w := serve(server, "GET", "/charges")
+	if body := strings.TrimSpace(w.Body.String()); body != `{"id": "ch_1"}` {
+		t.Errorf("expected the file as the body, received %q", body)
+	}
+}
+
+// should answer from a script when one matches, and proxy to the fallback
+// upstream when none does, saying which it did
+func TestServerFallback(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"get.json": `{
+			"request": { "method": "get", "url": "/mocked" },
+			"response": { "status": 200, "body": { "mocked": true } }
+		}`,
+	})
+
+	server, err := MakeServer(&Config{ScriptDir: dir,
+		FallbackUpstream: upstream(t).URL})
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	w := serve(server, "GET", "/mocked")
+	if w.Code != 200 || w.Body.String() != `{"mocked":true}` ||
+		w.Header().Get(MockedHeader) != Mocked {
+		t.Errorf("expected a mocked 200, received %d %q %v", w.Code,
+			w.Body.String(), w.Header())
+	}
+
+	w = serve(server, "GET", "/elsewhere")
+	if w.Code != 201 || w.Body.String() != "GET /elsewhere " ||
+		w.Header().Get(MockedHeader) != Proxied {
+		t.Errorf("expected a proxied 201, received %d %q %v", w.Code,
+			w.Body.String(), w.Header())
+	}
+}
+
+// should answer an unmatched request with a 404 when there's no fallback
+func TestServerNoFallback(t *testing.T) {
+	server, err := MakeServer(&Config{ScriptDir: writeScripts(t, nil)})
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	if w := serve(server, "GET", "/elsewhere"); w.Code != 404 ||
+		w.Header().Get(MockedHeader) != "" {
+		t.Errorf("expected a bare 404, received %d %v", w.Code, w.Header())
+	}
+}
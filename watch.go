@@ -0,0 +1,93 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"path/filepath"
+	"time"
+)
+
+// snapshot records the size and modification time of each file in the script
+// directory, so that polling can tell when any of them changed.  Every file is
+// recorded, not only scripts, since a script's body file can have any name.
+type snapshot map[string]stamp
+
+type stamp struct {
+	modified time.Time
+	size     int64
+}
+
+func takeSnapshot(l *layout) (snapshot, error) {
+	snap := make(snapshot)
+
+	err := filepath.WalkDir(l.dir, func(p string, d fs.DirEntry,
+		err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		snap[p] = stamp{info.ModTime(), info.Size()}
+		return nil
+	})
+
+	return snap, err
+}
+
+func (s snapshot) equal(other snapshot) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for name, a := range s {
+		if b, ok := other[name]; !ok || a.size != b.size ||
+			!a.modified.Equal(b.modified) {
+			return false
+		}
+	}
+	return true
+}
+
+// Watch polls the script directory every interval, and reloads its scripts
+// whenever a file is added, removed or modified.  The new route tree is
+// swapped in only if every script loads; otherwise the last good tree keeps
+// serving.  TCP scripts are only read at startup.
+func (s *Server) Watch(interval time.Duration) {
+	last, err := takeSnapshot(s.layout)
+	if err != nil {
+		log.Printf("mocket: error watching scripts (%v)\n", err)
+	}
+
+	for range time.Tick(interval) {
+		snap, err := takeSnapshot(s.layout)
+		if err != nil {
+			log.Printf("mocket: error watching scripts (%v)\n", err)
+			continue
+		} else if snap.equal(last) {
+			continue
+		}
+		last = snap
+
+		if err := s.reload(); err != nil {
+			log.Printf("mocket: error reloading scripts, keeping the last "+
+				"good ones (%v)\n", err)
+		} else {
+			log.Printf("mocket: reloaded scripts from (%s)\n", s.layout.dir)
+		}
+	}
+}
+
+// reload loads the scripts again, swapping in the new route tree only if
+// every one of them loads.
+func (s *Server) reload() error {
+	loaded, err := loadScripts(s.layout)
+	if err != nil {
+		return err
+	}
+
+	s.scenarios.Declare(loaded.scenarios)
+	s.path.Store(&loaded.path)
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// should tell when a file is added, removed or modified
+func TestSnapshotEqual(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"users.json":     `{}`,
+		"billing/a.json": `{}`,
+		"notes.txt":      "not a script",
+	})
+	l, _ := makeLayout(&Config{ScriptDir: dir})
+
+	take := func() snapshot {
+		snap, err := takeSnapshot(l)
+		if err != nil {
+			t.Fatalf("received error (%v)", err)
+		}
+		return snap
+	}
+
+	last := take()
+	if !last.equal(take()) {
+		t.Error("expected an unchanged directory to be equal")
+	}
+
+	changes := []struct {
+		name   string
+		change func() error
+	}{
+		{"added", func() error {
+			return os.WriteFile(filepath.Join(dir, "billing", "b.json"),
+				[]byte(`{}`), 0o644)
+		}},
+		{"removed", func() error {
+			return os.Remove(filepath.Join(dir, "users.json"))
+		}},
+		{"resized", func() error {
+			return os.WriteFile(filepath.Join(dir, "billing", "a.json"),
+				[]byte(`{ }`), 0o644)
+		}},
+		{"touched", func() error {
+			later := time.Now().Add(time.Hour)
+			return os.Chtimes(filepath.Join(dir, "billing", "a.json"), later,
+				later)
+		}},
+		{"body", func() error {
+			return os.WriteFile(filepath.Join(dir, "billing", "report.pdf"),
+				[]byte("%PDF"), 0o644)
+		}},
+		{"text", func() error {
+			return os.WriteFile(filepath.Join(dir, "notes.txt"),
+				[]byte("changed"), 0o644)
+		}},
+	}
+
+	for _, c := range changes {
+		if err := c.change(); err != nil {
+			t.Fatalf("received error (%v)", err)
+		}
+
+		snap := take()
+		if last.equal(snap) {
+			t.Errorf("expected a %s change to be noticed", c.name)
+		}
+		last = snap
+	}
+}
+
+// should swap in the new scripts only when every one of them loads
+func TestServerReload(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"users.json": `{
+			"request": { "method": "get", "url": "/users" },
+			"response": { "status": 200 }
+		}`,
+	})
+
+	server, err := MakeServer(&Config{ScriptDir: dir})
+	if err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+
+	write := func(rel string, content string) {
+		err := os.WriteFile(filepath.Join(dir, rel), []byte(content), 0o644)
+		if err != nil {
+			t.Fatalf("received error (%v)", err)
+		}
+	}
+
+	write("orders.json", `{ "request": { "method": "get", "url": "/orders" `)
+	if err := server.reload(); err == nil {
+		t.Error("expected a broken script to fail the reload")
+	}
+	if w := serve(server, "GET", "/users"); w.Code != 200 {
+		t.Errorf("expected the last good scripts to keep serving, "+
+			"received %d", w.Code)
+	}
+
+	write("orders.json", `{
+		"request": { "method": "get", "url": "/orders" },
+		"response": { "status": 200, "bodyFile": "orders.csv" }
+	}`)
+	if err := server.reload(); err == nil {
+		t.Error("expected a missing body file to fail the reload")
+	}
+	if w := serve(server, "GET", "/orders"); w.Code != 404 {
+		t.Errorf("expected the script not to load yet, received %d", w.Code)
+	}
+
+	write("orders.csv", "id\n1\n")
+	if err := server.reload(); err != nil {
+		t.Fatalf("received error (%v)", err)
+	}
+	for _, url := range []string{"/users", "/orders"} {
+		if w := serve(server, "GET", url); w.Code != 200 {
+			t.Errorf("%s: expected the new scripts to serve, received %d",
+				url, w.Code)
+		}
+	}
+	if w := serve(server, "GET", "/orders"); w.Body.String() != "id\n1\n" {
+		t.Errorf("expected the body file, received %q", w.Body.String())
+	}
+}